@@ -0,0 +1,329 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/andrew-d/go-termutil"
+	"github.com/codegangsta/cli"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const DOWNLOAD_PROGRESS_INTERVAL time.Duration = 100 * time.Millisecond
+
+/// Download artifacts of a job, or of the latest successful job of a name for --ref, optionally extracting them
+func downloadArtifacts(c *cli.Context) {
+	server := needGitlab(c)
+	server.token = needToken(c)
+	projectId := needRemoteUrl(c).path
+
+	path := strings.Trim(c.String("path"), "/")
+	extract := c.String("extract")
+	if extract != "" && path != "" {
+		log.Fatal("Only archives can be extracted, not single files of --path")
+	}
+	dest := c.String("save")
+	if dest == "" {
+		dest = "artifacts.zip"
+		if path != "" {
+			dest = filepath.Base(path)
+		}
+	}
+	if dest == "-" && (extract != "" || c.Bool("continue")) {
+		log.Fatal("Downloads to stdout can neither be extracted nor continued")
+	}
+
+	var j *job
+	var err error
+	if ref := c.String("ref"); ref != "" {
+		name := c.Args().First()
+		if name == "" {
+			log.Fatal("Name the job to get the artifacts of its latest successful run for ", ref)
+		}
+		j, err = server.findLatestSuccessfulJob(projectId, ref, name)
+	} else {
+		j, err = jobFromContext(c, server, projectId, "")
+	}
+	if err == ErrNoSelection {
+		return
+	}
+	if nil != err {
+		log.Fatal(err)
+	}
+
+	download := func(offset int64, validator string) (*http.Response, error) {
+		return server.downloadJobArtifacts(projectId, j.Id, path, offset, validator)
+	}
+	source := fmt.Sprintf("%s job %d artifacts %s", projectId, j.Id, path)
+	err = saveDownload(download, dest, source, c.Bool("continue"))
+	if nil != err {
+		log.Fatal(err)
+	}
+	if dest == "-" {
+		return
+	}
+	log.Printf("Saved artifacts of job #%d %s/%s to: %s\n", j.Id, j.Stage, j.Name, dest)
+
+	if extract != "" {
+		count, err := extractZip(dest, extract)
+		if nil != err {
+			log.Fatal(err)
+		}
+		log.Printf("Extracted %d files to: %s\n", count, extract)
+	}
+}
+
+/// Download kept in "<dest>.part" until complete, described by "<dest>.part.json" to continue it
+type partialDownload struct {
+	Source    string `json:"source"`
+	Validator string `json:"validator"`
+}
+
+/// Stream a download to a file, or stdout for "-". With resume, a partial download of the same source
+/// is continued as long as the server still has the same file, as told by its ETag or Last-Modified
+func saveDownload(download func(offset int64, validator string) (*http.Response, error), dest string, source string, resume bool) error {
+	if dest == "-" {
+		resp, err := download(0, "")
+		if nil != err {
+			return err
+		}
+		defer resp.Body.Close()
+		_, err = io.Copy(os.Stdout, resp.Body)
+		return err
+	}
+
+	part := dest + ".part"
+	var offset int64
+	var validator string
+	if resume {
+		offset, validator = resumableDownload(part, source)
+	}
+
+	resp, err := download(offset, validator)
+	if nil != err {
+		return err
+	}
+	if resp.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		resp.Body.Close()
+		if downloadSize(resp) == offset {
+			// Complete already
+			return finishDownload(part, dest)
+		}
+		offset = 0
+		resp, err = download(0, "")
+		if nil != err {
+			return err
+		}
+	}
+	defer resp.Body.Close()
+
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if resp.StatusCode == http.StatusPartialContent {
+		flags = os.O_WRONLY | os.O_APPEND
+	} else {
+		offset = 0
+	}
+	file, err := os.OpenFile(part, flags, 0644)
+	if nil != err {
+		return err
+	}
+	defer file.Close()
+
+	info, err := json.Marshal(partialDownload{Source: source, Validator: downloadValidator(resp)})
+	if nil != err {
+		return err
+	}
+	err = ioutil.WriteFile(part+".json", info, 0644)
+	if nil != err {
+		return err
+	}
+
+	progress := &downloadProgress{name: dest, done: offset, total: -1}
+	if resp.ContentLength >= 0 {
+		progress.total = offset + resp.ContentLength
+	}
+	if termutil.Isatty(os.Stderr.Fd()) {
+		progress.out = os.Stderr
+	}
+
+	_, err = io.Copy(file, io.TeeReader(resp.Body, progress))
+	progress.finish()
+	if nil != err {
+		return err
+	}
+	err = file.Close()
+	if nil != err {
+		return err
+	}
+
+	return finishDownload(part, dest)
+}
+
+/// Size and validator of a partial download of source to continue, none if it is of something else
+func resumableDownload(part, source string) (int64, string) {
+	data, err := ioutil.ReadFile(part + ".json")
+	if nil != err {
+		return 0, ""
+	}
+	var partial partialDownload
+	if nil != json.Unmarshal(data, &partial) || partial.Source != source || partial.Validator == "" {
+		return 0, ""
+	}
+	stat, err := os.Stat(part)
+	if nil != err {
+		return 0, ""
+	}
+	return stat.Size(), partial.Validator
+}
+
+/// Strong ETag or else Last-Modified of a download, as accepted by If-Range
+func downloadValidator(resp *http.Response) string {
+	if etag := resp.Header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+	return resp.Header.Get("Last-Modified")
+}
+
+/// Full size of a download from the Content-Range of a partial or unsatisfiable response, -1 if unknown
+func downloadSize(resp *http.Response) int64 {
+	contentRange := resp.Header.Get("Content-Range")
+	i := strings.LastIndex(contentRange, "/")
+	if i < 0 {
+		return -1
+	}
+	size, err := strconv.ParseInt(contentRange[i+1:], 10, 64)
+	if nil != err {
+		return -1
+	}
+	return size
+}
+
+/// Move a complete download in place
+func finishDownload(part, dest string) error {
+	err := os.Rename(part, dest)
+	if nil != err {
+		return err
+	}
+	os.Remove(part + ".json")
+	return nil
+}
+
+/// Progress of a download shown on a terminal, at most every DOWNLOAD_PROGRESS_INTERVAL
+type downloadProgress struct {
+	out   io.Writer
+	name  string
+	done  int64
+	total int64
+	shown time.Time
+}
+
+func (p *downloadProgress) Write(b []byte) (int, error) {
+	p.done += int64(len(b))
+	if nil != p.out && time.Since(p.shown) >= DOWNLOAD_PROGRESS_INTERVAL {
+		p.show()
+	}
+	return len(b), nil
+}
+
+func (p *downloadProgress) show() {
+	p.shown = time.Now()
+	if p.total > 0 {
+		fmt.Fprintf(p.out, "\r\x1b[K%s %s / %s %d%%", p.name, byteSize(p.done), byteSize(p.total), p.done*100/p.total)
+		return
+	}
+	fmt.Fprintf(p.out, "\r\x1b[K%s %s", p.name, byteSize(p.done))
+}
+
+func (p *downloadProgress) finish() {
+	if nil == p.out {
+		return
+	}
+	p.show()
+	fmt.Fprintln(p.out)
+}
+
+/// Human readable size, eg. "1.5 MiB"
+func byteSize(n int64) string {
+	if n < 1024 {
+		return fmt.Sprintf("%d B", n)
+	}
+	size := float64(n)
+	for _, unit := range []string{"KiB", "MiB", "GiB"} {
+		size /= 1024
+		if size < 1024 || unit == "GiB" {
+			return fmt.Sprintf("%.1f %s", size, unit)
+		}
+	}
+	return ""
+}
+
+/// Extract a zip archive into dir, refusing entries outside of it, returns the number of files
+func extractZip(archive, dir string) (int, error) {
+	r, err := zip.OpenReader(archive)
+	if nil != err {
+		return 0, err
+	}
+	defer r.Close()
+
+	root, err := filepath.Abs(dir)
+	if nil != err {
+		return 0, err
+	}
+
+	count := 0
+	for _, f := range r.File {
+		target := filepath.Join(root, filepath.FromSlash(f.Name))
+		rel, err := filepath.Rel(root, target)
+		if nil != err || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return count, errors.New("Refusing to extract outside of " + dir + ": " + f.Name)
+		}
+
+		if f.FileInfo().IsDir() {
+			err = os.MkdirAll(target, 0755)
+			if nil != err {
+				return count, err
+			}
+			continue
+		}
+
+		err = os.MkdirAll(filepath.Dir(target), 0755)
+		if nil != err {
+			return count, err
+		}
+		err = extractZipFile(f, target)
+		if nil != err {
+			return count, err
+		}
+		count++
+	}
+
+	return count, nil
+}
+
+func extractZipFile(f *zip.File, target string) error {
+	in, err := f.Open()
+	if nil != err {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, f.Mode().Perm()|0200)
+	if nil != err {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if nil != err {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
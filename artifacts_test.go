@@ -0,0 +1,134 @@
+package main
+
+import (
+	"archive/zip"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestByteSize(t *testing.T) {
+	for n, expected := range map[int64]string{
+		0:                  "0 B",
+		1023:               "1023 B",
+		1536:               "1.5 KiB",
+		5 * 1024 * 1024:    "5.0 MiB",
+		3 << 40:            "3072.0 GiB",
+		1024*1024*1024 - 1: "1024.0 MiB",
+	} {
+		if size := byteSize(n); size != expected {
+			t.Fatalf("Expected %d bytes as %s, got: %s\n", n, expected, size)
+		}
+	}
+}
+
+func writeTestZip(t *testing.T, dir string, files map[string]string) string {
+	archive := filepath.Join(dir, "artifacts.zip")
+	out, err := os.Create(archive)
+	if nil != err {
+		t.Fatal(err)
+	}
+	w := zip.NewWriter(out)
+	for name, content := range files {
+		f, err := w.Create(name)
+		if nil != err {
+			t.Fatal(err)
+		}
+		f.Write([]byte(content))
+	}
+	if err = w.Close(); nil != err {
+		t.Fatal(err)
+	}
+	out.Close()
+	return archive
+}
+
+func TestExtractZip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lab-artifacts")
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	archive := writeTestZip(t, dir, map[string]string{
+		"build/lab":    "binary",
+		"build/doc/":   "",
+		"coverage.out": "mode: set",
+	})
+	count, err := extractZip(archive, filepath.Join(dir, "out"))
+	if nil != err {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Fatal("Expected 2 files, got:", count)
+	}
+	content, err := ioutil.ReadFile(filepath.Join(dir, "out", "build", "lab"))
+	if nil != err || string(content) != "binary" {
+		t.Fatal("Expected extracted file, got:", string(content), err)
+	}
+
+	archive = writeTestZip(t, dir, map[string]string{"../escaped": "oops"})
+	if _, err = extractZip(archive, filepath.Join(dir, "out")); nil == err {
+		t.Fatal("Expected error extracting outside of the directory")
+	}
+	if _, err = os.Stat(filepath.Join(dir, "escaped")); nil == err {
+		t.Fatal("Expected no file outside of the directory")
+	}
+}
+
+func TestSaveDownload(t *testing.T) {
+	artifacts := "PK complete artifacts"
+	etag := `"v1"`
+	sr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", etag)
+		http.ServeContent(w, r, "artifacts.zip", time.Time{}, strings.NewReader(artifacts))
+	}))
+	defer sr.Close()
+
+	g := newGitlab(urlMustParse(t, sr.URL).Host)
+	download := func(offset int64, validator string) (*http.Response, error) {
+		return g.downloadJobArtifacts("group/project", 7, "", offset, validator)
+	}
+
+	dir, err := ioutil.TempDir("", "lab-artifacts")
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	dest := filepath.Join(dir, "artifacts.zip")
+	source := "group/project job 7 artifacts "
+
+	for _, c := range []struct {
+		partial, source, validator string
+	}{
+		{"PK comp", source, etag},
+		{"PK old ", source, `"v0"`},
+		{"PK othe", "group/project job 6 artifacts ", etag},
+		{artifacts, source, etag},
+		{artifacts + " and more", source, etag},
+	} {
+		if err = ioutil.WriteFile(dest+".part", []byte(c.partial), 0644); nil != err {
+			t.Fatal(err)
+		}
+		info := fmt.Sprintf(`{"source":%q,"validator":%q}`, c.source, c.validator)
+		if err = ioutil.WriteFile(dest+".part.json", []byte(info), 0644); nil != err {
+			t.Fatal(err)
+		}
+		if err = saveDownload(download, dest, source, true); nil != err {
+			t.Fatal(err)
+		}
+		content, _ := ioutil.ReadFile(dest)
+		if string(content) != artifacts {
+			t.Fatalf("Expected %q after continuing %q of %s, got: %q\n", artifacts, c.partial, c.validator, string(content))
+		}
+		if _, err = os.Stat(dest + ".part.json"); nil == err {
+			t.Fatal("Expected no partial download left")
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"fmt"
+	"github.com/codegangsta/cli"
+	"log"
+	"strconv"
+	"strings"
+)
+
+/// Pipeline by ID, or the latest of a branch or tag, given as first argument, defaulting to the current branch
+func pipelineFromContext(c *cli.Context, server gitlab, projectId string) (*pipeline, error) {
+	arg := c.Args().First()
+	if pipelineId, err := strconv.Atoi(arg); nil == err {
+		return server.getPipeline(projectId, pipelineId)
+	}
+
+	ref := arg
+	if ref == "" {
+		var err error
+		ref, err = needGitDir(c).getCurrentBranch()
+		if nil != err {
+			return nil, err
+		}
+	}
+
+	return server.getLatestPipeline(projectId, ref)
+}
+
+/// Show the latest pipeline of the current branch, or as given, with the status of its stages and jobs
+func showPipelineStatus(c *cli.Context) {
+	if formatHelp(c, PipelineStatusTemplate, pipelineDetails{}) {
+		return
+	}
+
+	server := needGitlab(c)
+	server.token = needToken(c)
+	projectId := needRemoteUrl(c).path
+
+	p, err := pipelineFromContext(c, server, projectId)
+	if nil != err {
+		log.Fatal(err)
+	}
+	if c.Bool("web") {
+		browse(p.WebUrl)
+		return
+	}
+
+	details, err := server.getPipelineDetails(projectId, p.Id)
+	if nil != err {
+		log.Fatal(err)
+	}
+
+	r, err := newRenderer(c, "pipeline-status", PipelineStatusTemplate, pipelineColumns, false)
+	if nil != err {
+		log.Fatal(err)
+	}
+
+	defer startPager(c)()
+	err = r.render(*details)
+	if nil != err {
+		fatalPaging(err)
+	}
+	err = r.flush()
+	if nil != err {
+		fatalPaging(err)
+	}
+}
+
+/// List pipelines of the project as filtered by flags, up to --limit
+func listPipelines(c *cli.Context) {
+	if formatHelp(c, PipelineListTemplate, pipeline{}) {
+		return
+	}
+
+	r, err := newRenderer(c, "default-pipeline", PipelineListTemplate, pipelineColumns, true)
+	if nil != err {
+		log.Fatal(err)
+	}
+	r.withTable(c, pipelineTableColumns, pipelineTableDefaultColumns)
+
+	server := needGitlab(c)
+	server.token = needToken(c)
+	projectId := needRemoteUrl(c).path
+
+	query, err := pipelineQueryFromFlags(c, server)
+	if nil != err {
+		log.Fatal(err)
+	}
+
+	limit := newPageLimit(c, query)
+	stopPager := startPager(c)
+	err = server.eachPipelinePage(projectId, query, func(page []pipeline) error {
+		for _, p := range page[:limit.take(len(page))] {
+			err := r.render(p)
+			if nil != err {
+				return err
+			}
+		}
+		return limit.next()
+	})
+	if nil != err {
+		fatalPaging(err)
+	}
+
+	err = r.flush()
+	if nil != err {
+		fatalPaging(err)
+	}
+	stopPager()
+
+	err = r.printCount(limit.count, "pipelines")
+	if nil != err {
+		log.Fatal(err)
+	}
+}
+
+/// Action on the latest pipeline of the current branch, or as given, or on its --job by ID or name
+func createActionForPipeline(done string, pipelineAction func(gitlab, string, int) (*pipeline, error), jobAction func(gitlab, string, int) (*job, error)) func(*cli.Context) {
+	return func(c *cli.Context) {
+		server := needGitlab(c)
+		server.token = needToken(c)
+		projectId := needRemoteUrl(c).path
+
+		if name := c.String("job"); name != "" {
+			j, err := jobFromFlag(c, server, projectId, name)
+			if nil != err {
+				log.Fatal(err)
+			}
+			changed, err := jobAction(server, projectId, j.Id)
+			if nil != err {
+				log.Fatal(err)
+			}
+			log.Printf("%s job #%d %s/%s (%s): %s\n", done, changed.Id, changed.Stage, changed.Name, changed.Status, changed.WebUrl)
+			return
+		}
+
+		p, err := pipelineFromContext(c, server, projectId)
+		if nil != err {
+			log.Fatal(err)
+		}
+		changed, err := pipelineAction(server, projectId, p.Id)
+		if nil != err {
+			log.Fatal(err)
+		}
+		log.Printf("%s pipeline #%d of %s (%s): %s\n", done, changed.Id, changed.Ref, changed.Status, changed.WebUrl)
+	}
+}
+
+/// Job by ID, or by name in the pipeline given by arguments
+func jobFromFlag(c *cli.Context, server gitlab, projectId string, name string) (*job, error) {
+	if jobId, err := strconv.Atoi(name); nil == err {
+		return server.getJob(projectId, jobId)
+	}
+
+	p, err := pipelineFromContext(c, server, projectId)
+	if nil != err {
+		return nil, err
+	}
+	return server.findJob(projectId, p.Id, name)
+}
+
+/// Run a new pipeline for the current branch, or a given branch or tag, with --var variables
+func runPipeline(c *cli.Context) {
+	server := needGitlab(c)
+	server.token = needToken(c)
+	projectId := needRemoteUrl(c).path
+
+	variables, err := pipelineVariables(c.StringSlice("var"))
+	if nil != err {
+		log.Fatal(err)
+	}
+
+	ref := c.Args().First()
+	if ref == "" {
+		ref, err = needGitDir(c).getCurrentBranch()
+		if nil != err {
+			log.Fatal(err)
+		}
+	}
+
+	created, err := server.createPipeline(projectId, ref, variables)
+	if nil != err {
+		log.Fatal(err)
+	}
+	log.Printf("Running pipeline #%d of %s: %s\n", created.Id, created.Ref, created.WebUrl)
+
+	if c.Bool("web") {
+		browse(created.WebUrl)
+	}
+}
+
+/// Start a manual job by ID, or by name or picked from the latest pipeline of the current branch, or --pipeline
+func playJob(c *cli.Context) {
+	server := needGitlab(c)
+	server.token = needToken(c)
+	projectId := needRemoteUrl(c).path
+
+	variables, err := pipelineVariables(c.StringSlice("var"))
+	if nil != err {
+		log.Fatal(err)
+	}
+
+	j, err := jobFromContext(c, server, projectId, "manual")
+	if err == ErrNoSelection {
+		return
+	}
+	if nil != err {
+		log.Fatal(err)
+	}
+
+	played, err := server.playJob(projectId, j.Id, variables)
+	if nil != err {
+		log.Fatal(err)
+	}
+	log.Printf("Started job #%d %s/%s (%s): %s\n", played.Id, played.Stage, played.Name, played.Status, played.WebUrl)
+}
+
+/// Variables from KEY=VALUE arguments
+func pipelineVariables(args []string) ([]pipelineVariable, error) {
+	var variables []pipelineVariable
+	for _, arg := range args {
+		i := strings.Index(arg, "=")
+		if i < 1 {
+			return nil, fmt.Errorf("Expected variable as KEY=VALUE, got: %s\n", arg)
+		}
+		variables = append(variables, pipelineVariable{Key: arg[:i], Value: arg[i+1:]})
+	}
+	return variables, nil
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestPipelineVariables(t *testing.T) {
+	variables, err := pipelineVariables([]string{"DEPLOY=staging", "FLAGS=-v --race=1", "EMPTY="})
+	if nil != err {
+		t.Fatal(err)
+	}
+	expected := []pipelineVariable{{"DEPLOY", "staging"}, {"FLAGS", "-v --race=1"}, {"EMPTY", ""}}
+	if len(variables) != len(expected) {
+		t.Fatal("Expected variables:", expected, "got:", variables)
+	}
+	for i := range expected {
+		if variables[i] != expected[i] {
+			t.Fatal("Expected variables:", expected, "got:", variables)
+		}
+	}
+
+	for _, invalid := range []string{"DEPLOY", "=staging"} {
+		if _, err := pipelineVariables([]string{invalid}); nil == err {
+			t.Fatal("Expected error for:", invalid)
+		}
+	}
+}
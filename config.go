@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"github.com/codegangsta/cli"
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+/// GitLab instance of [[instances]], eg. for lab serve
+type instanceConfig struct {
+	Url          string `toml:"url"`
+	PrivateToken string `toml:"private_token"`
+}
+
+/// Read ~/.labrc and $PROJECT/.lab, the project one wins
+func readConfig(c *cli.Context) (config, error) {
+	merged := config{Formats: make(map[string]string)}
+
+	var files []string
+	if home := os.Getenv("HOME"); home != "" {
+		files = append(files, filepath.Join(home, ".labrc"))
+	}
+	if wd, err := needGitDir(c).Getwd(); nil == err {
+		files = append(files, filepath.Join(wd, ".lab"))
+	}
+
+	for _, file := range files {
+		var config config
+		_, err := toml.DecodeFile(file, &config)
+		if nil != err {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return merged, fmt.Errorf("Could not read %s: %s\n", file, err)
+		}
+
+		if config.PrivateToken != "" {
+			merged.PrivateToken = config.PrivateToken
+		}
+		for name, format := range config.Formats {
+			merged.Formats[name] = format
+		}
+		merged.Instances = append(merged.Instances, config.Instances...)
+		if config.IssueBranch != "" {
+			merged.IssueBranch = config.IssueBranch
+		}
+	}
+
+	return merged, nil
+}
+
+/// Get client of a configured instance
+func (i instanceConfig) gitlab() (gitlab, error) {
+	u, err := url.Parse(i.Url)
+	if nil != err || u.Host == "" {
+		return gitlab{}, fmt.Errorf("Invalid instance url: %s, eg. https://gitlab.example.com\n", i.Url)
+	}
+	if i.PrivateToken == "" {
+		return gitlab{}, fmt.Errorf("Missing private_token for instance: %s\n", i.Url)
+	}
+
+	server := newGitlab(u.Host)
+	server.scheme = u.Scheme
+	server.token = i.PrivateToken
+	return server, nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+/// Line of a unified diff with its line numbers in the old and new file
+type diffLine struct {
+	Kind    string // "+", "-", " " for context, "@" for hunk headers and "" for file headers
+	OldLine int
+	NewLine int
+	Text    string
+}
+
+/// Parse unified diff, numbering lines from the hunk headers
+func parseDiff(diff string) []diffLine {
+	var lines []diffLine
+	var oldLine, newLine int
+	inHunk := false
+
+	for _, text := range strings.Split(strings.TrimSuffix(diff, "\n"), "\n") {
+		switch {
+		case strings.HasPrefix(text, "@@"):
+			oldLine, newLine = parseHunkHeader(text)
+			inHunk = true
+			lines = append(lines, diffLine{Kind: "@", Text: text})
+		case !inHunk || strings.HasPrefix(text, "diff "):
+			inHunk = false
+			lines = append(lines, diffLine{Text: text})
+		case strings.HasPrefix(text, "+"):
+			lines = append(lines, diffLine{Kind: "+", NewLine: newLine, Text: text})
+			newLine++
+		case strings.HasPrefix(text, "-"):
+			lines = append(lines, diffLine{Kind: "-", OldLine: oldLine, Text: text})
+			oldLine++
+		case strings.HasPrefix(text, `\`):
+			// "\ No newline at end of file"
+		default:
+			lines = append(lines, diffLine{Kind: " ", OldLine: oldLine, NewLine: newLine, Text: text})
+			oldLine++
+			newLine++
+		}
+	}
+
+	return lines
+}
+
+/// Get starting old and new line numbers from eg. "@@ -12,7 +12,8 @@ func main() {"
+func parseHunkHeader(header string) (oldLine, newLine int) {
+	fields := strings.Fields(header)
+	if len(fields) < 3 {
+		return 0, 0
+	}
+
+	parseStart := func(field, prefix string) int {
+		field = strings.TrimPrefix(field, prefix)
+		if i := strings.IndexByte(field, ','); i >= 0 {
+			field = field[:i]
+		}
+		n, _ := strconv.Atoi(field)
+		return n
+	}
+
+	return parseStart(fields[1], "-"), parseStart(fields[2], "+")
+}
+
+/// Find diff line by new line number, or old line number when prefixed with "-"
+func findDiffLine(lines []diffLine, ref string) (*diffLine, error) {
+	old := strings.HasPrefix(ref, "-")
+	n, err := strconv.Atoi(strings.TrimPrefix(ref, "-"))
+	if nil != err || n <= 0 {
+		return nil, fmt.Errorf("Not a line number: %s\n", ref)
+	}
+
+	for i, line := range lines {
+		if old && line.OldLine == n && line.Kind != "+" && line.Kind != "@" {
+			return &lines[i], nil
+		}
+		if !old && line.NewLine == n && line.Kind != "-" && line.Kind != "@" {
+			return &lines[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("Line %s is not part of the diff\n", ref)
+}
+
+/// Old and new line numbers for display in front of the line
+func (l diffLine) Gutter() string {
+	number := func(n int) string {
+		if n == 0 {
+			return ""
+		}
+		return strconv.Itoa(n)
+	}
+
+	return fmt.Sprintf("%5s %5s", number(l.OldLine), number(l.NewLine))
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"testing"
+)
+
+const testDiff = `@@ -1,4 +1,4 @@
+ package main
+-import "fmt"
++import "log"
+ 
+ func main() {
+\ No newline at end of file
+`
+
+func TestParseDiff(t *testing.T) {
+	lines := parseDiff(testDiff)
+
+	expected := []diffLine{
+		{"@", 0, 0, "@@ -1,4 +1,4 @@"},
+		{" ", 1, 1, " package main"},
+		{"-", 2, 0, `-import "fmt"`},
+		{"+", 0, 2, `+import "log"`},
+		{" ", 3, 3, " "},
+		{" ", 4, 4, " func main() {"},
+	}
+
+	if len(lines) != len(expected) {
+		t.Fatalf("Expected %d lines, got: %+v\n", len(expected), lines)
+	}
+	for i := range expected {
+		if lines[i] != expected[i] {
+			t.Fatalf("Expected line %d to be %+v, got: %+v\n", i, expected[i], lines[i])
+		}
+	}
+}
+
+func TestParseHunkHeader(t *testing.T) {
+	oldLine, newLine := parseHunkHeader("@@ -12,7 +15 @@ func main() {")
+	if oldLine != 12 || newLine != 15 {
+		t.Fatal("Expected 12 and 15, got:", oldLine, newLine)
+	}
+}
+
+func TestFindDiffLine(t *testing.T) {
+	lines := parseDiff(testDiff)
+
+	line, err := findDiffLine(lines, "2")
+	if nil != err {
+		t.Fatal(err)
+	}
+	if line.Text != `+import "log"` {
+		t.Fatal("Expected added line, got:", line.Text)
+	}
+
+	line, err = findDiffLine(lines, "-2")
+	if nil != err {
+		t.Fatal(err)
+	}
+	if line.Text != `-import "fmt"` {
+		t.Fatal("Expected removed line, got:", line.Text)
+	}
+
+	if _, err = findDiffLine(lines, "42"); nil == err {
+		t.Fatal("Expected error for line outside of the diff")
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"github.com/codegangsta/cli"
+	"log"
+	"strconv"
+	"strings"
+)
+
+/// Show discussion threads of a merge request
+func showDiscussions(c *cli.Context, server gitlab, projectId string, req mergeRequest) error {
+	if formatHelp(c, MergeRequestDiscussionTemplate, discussion{}) {
+		return nil
+	}
+
+	r, err := newRenderer(c, "merge-request-discussion", MergeRequestDiscussionTemplate, discussionColumns, true)
+	if nil != err {
+		return err
+	}
+
+	discussions, err := server.getMergeRequestDiscussions(projectId, req.Iid)
+	if nil != err {
+		return err
+	}
+
+	for _, d := range discussions {
+		if d.System() && !c.Bool("system") {
+			continue
+		}
+		if c.Bool("unresolved") && (!d.Resolvable() || d.Resolved()) {
+			continue
+		}
+
+		err = r.render(d)
+		if nil != err {
+			return err
+		}
+	}
+
+	return r.flush()
+}
+
+/// Post a note on a merge request from argument, --message, stdin or $EDITOR
+func commentOnMergeRequest(c *cli.Context, server gitlab, projectId string, req mergeRequest) error {
+	message := strings.Join(mergeRequestArgs(c), " ")
+	if message == "" {
+		message = c.String("message")
+	}
+
+	body, err := readMessage(message, fmt.Sprintf("Comment on !%d: %s", req.Iid, req.Title))
+	if nil != err {
+		return err
+	}
+
+	created, err := server.createMergeRequestNote(projectId, req.Iid, body)
+	if nil != err {
+		return err
+	}
+
+	log.Println("Commented:", server.getMergeRequestUrl(projectId, req.Iid)+"#note_"+strconv.Itoa(created.Id))
+	return nil
+}
+
+/// Resolve (or unresolve) discussion threads picked by (abbreviated) id
+func resolveDiscussions(c *cli.Context, server gitlab, projectId string, req mergeRequest) error {
+	ids := append(mergeRequestArgs(c), c.StringSlice("discussion")...)
+	if len(ids) == 0 && !c.Bool("all") {
+		return fmt.Errorf("No discussions given, see: lab mr comments --unresolved")
+	}
+
+	discussions, err := server.getMergeRequestDiscussions(projectId, req.Iid)
+	if nil != err {
+		return err
+	}
+
+	resolved := !c.Bool("unresolve")
+	var picked []discussion
+	if c.Bool("all") {
+		for _, d := range discussions {
+			if d.Resolvable() && d.Resolved() != resolved {
+				picked = append(picked, d)
+			}
+		}
+	}
+	for _, id := range ids {
+		d, err := findDiscussion(discussions, id)
+		if nil != err {
+			return err
+		}
+		picked = append(picked, *d)
+	}
+
+	for _, d := range picked {
+		_, err = server.resolveMergeRequestDiscussion(projectId, req.Iid, d.Id, resolved)
+		if nil != err {
+			return err
+		}
+		if resolved {
+			log.Println("Resolved discussion:", d.ShortId())
+		} else {
+			log.Println("Unresolved discussion:", d.ShortId())
+		}
+	}
+
+	return nil
+}
+
+/// Find a resolvable discussion by unambiguous id prefix
+func findDiscussion(discussions []discussion, id string) (*discussion, error) {
+	var found *discussion
+	for i, d := range discussions {
+		if !strings.HasPrefix(d.Id, id) {
+			continue
+		}
+		if found != nil {
+			return nil, fmt.Errorf("Ambiguous discussion: %s\n", id)
+		}
+		found = &discussions[i]
+	}
+
+	if found == nil {
+		return nil, fmt.Errorf("Could not find discussion: %s\n", id)
+	}
+	if !found.Resolvable() {
+		return nil, fmt.Errorf("Discussion can not be resolved: %s\n", id)
+	}
+
+	return found, nil
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"flag"
+	"github.com/codegangsta/cli"
+	"reflect"
+	"testing"
+)
+
+func TestMergeRequestArgs(t *testing.T) {
+	for _, args := range [][]string{
+		[]string{"Looks good"},
+		[]string{"12", "Looks good"},
+		[]string{"!1234567", "Looks good"},
+		[]string{"Looks", "good"},
+		[]string{"12345678"},
+	} {
+		set := flag.NewFlagSet("test", flag.ContinueOnError)
+		set.Parse(args)
+		rest := mergeRequestArgs(cli.NewContext(nil, set, nil))
+		expected := args
+		if args[0] == "12" || args[0] == "!1234567" {
+			expected = args[1:]
+		}
+		if !reflect.DeepEqual(rest, expected) {
+			t.Fatalf("Expected %q after the ID of %q, got: %q\n", expected, args, rest)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"github.com/andrew-d/go-termutil"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+var ErrEmptyMessage = errors.New("Aborting due to empty message")
+
+/// Get message from argument, stdin (when piped or "-") or $EDITOR
+func readMessage(arg, help string) (string, error) {
+	if arg != "" && arg != "-" {
+		return arg, nil
+	}
+
+	if arg == "-" || !termutil.Isatty(os.Stdin.Fd()) {
+		input, err := ioutil.ReadAll(os.Stdin)
+		if nil != err {
+			return "", err
+		}
+		return nonEmptyMessage(string(input))
+	}
+
+	return editMessage("", help)
+}
+
+/// Let the user write a message in $EDITOR, lines starting with "#" are dropped
+func editMessage(initial, help string) (string, error) {
+	f, err := ioutil.TempFile("", "lab-message-")
+	if nil != err {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+
+	contents := initial + "\n"
+	for _, line := range strings.Split(help, "\n") {
+		contents += "# " + line + "\n"
+	}
+	_, err = f.WriteString(contents)
+	f.Close()
+	if nil != err {
+		return "", err
+	}
+
+	cmd := exec.Command("sh", "-c", getEditor()+` "$@"`, "--", f.Name())
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	err = cmd.Run()
+	if nil != err {
+		return "", err
+	}
+
+	edited, err := ioutil.ReadFile(f.Name())
+	if nil != err {
+		return "", err
+	}
+
+	var lines []string
+	for _, line := range strings.Split(string(edited), "\n") {
+		if !strings.HasPrefix(line, "#") {
+			lines = append(lines, line)
+		}
+	}
+
+	return nonEmptyMessage(strings.Join(lines, "\n"))
+}
+
+/// Editor command: $VISUAL, $EDITOR, git's core.editor or vi
+func getEditor() string {
+	for _, env := range []string{"VISUAL", "EDITOR"} {
+		if editor := os.Getenv(env); editor != "" {
+			return editor
+		}
+	}
+
+	output, err := exec.Command("git", "var", "GIT_EDITOR").Output()
+	if nil == err {
+		if editor := strings.TrimSpace(string(output)); editor != "" {
+			return editor
+		}
+	}
+
+	return "vi"
+}
+
+func nonEmptyMessage(message string) (string, error) {
+	message = strings.TrimSpace(message)
+	if message == "" {
+		return "", ErrEmptyMessage
+	}
+	return message, nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"github.com/codegangsta/cli"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+/// Named formats of [formats] in ~/.labrc and $PROJECT/.lab, the project ones win
+func namedFormats(c *cli.Context) (map[string]string, error) {
+	config, err := readConfig(c)
+	if nil != err {
+		return nil, err
+	}
+	return config.Formats, nil
+}
+
+/// Get template of --format, "@name" for a named format, or defaultFormat if not given
+func resolveFormat(c *cli.Context, defaultFormat string) (string, error) {
+	format := c.String("format")
+	if format == "" {
+		return defaultFormat, nil
+	}
+	if !strings.HasPrefix(format, "@") {
+		return format, nil
+	}
+
+	formats, err := namedFormats(c)
+	if nil != err {
+		return "", err
+	}
+	named, ok := formats[strings.TrimPrefix(format, "@")]
+	if !ok {
+		return "", fmt.Errorf("Unknown format: %s, define it in [formats] of ~/.labrc or .lab\n", format)
+	}
+	return named, nil
+}
+
+/// Show default template, fields and functions for --format help, returns whether help was shown
+func formatHelp(c *cli.Context, defaultFormat string, item interface{}) bool {
+	if c.String("format") != "help" {
+		return false
+	}
+
+	fmt.Print("Default format:\n", defaultFormat, "\n")
+
+	fmt.Println("Fields:")
+	for _, field := range templateFields(reflect.TypeOf(item), "", 0) {
+		fmt.Println("  " + field)
+	}
+
+	var names []string
+	for name := range templateFuncs[false] {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Printf("\nFunctions:\n  %s\n", strings.Join(names, ", "))
+
+	formats, err := namedFormats(c)
+	if nil == err && len(formats) > 0 {
+		names = names[:0]
+		for name := range formats {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		fmt.Println("\nNamed formats:")
+		for _, name := range names {
+			fmt.Printf("  @%s\n", name)
+		}
+	}
+
+	return true
+}
+
+/// Fields and methods usable in templates, eg. ".Author.Username string", nested up to two levels
+func templateFields(t reflect.Type, prefix string, depth int) []string {
+	var fields []string
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return fields
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		name := prefix + "." + field.Name
+		fields = append(fields, name+" "+typeName(field.Type))
+
+		nested := field.Type
+		if nested.Kind() == reflect.Ptr {
+			nested = nested.Elem()
+		}
+		if nested.Kind() == reflect.Struct && nested != reflect.TypeOf(time.Time{}) && depth < 2 {
+			fields = append(fields, templateFields(nested, name, depth+1)...)
+		}
+	}
+
+	for i := 0; i < t.NumMethod(); i++ {
+		method := t.Method(i)
+		if method.Type.NumIn() != 1 || method.Type.NumOut() == 0 {
+			continue
+		}
+		fields = append(fields, prefix+"."+method.Name+" "+typeName(method.Type.Out(0)))
+	}
+
+	return fields
+}
+
+func typeName(t reflect.Type) string {
+	return strings.Replace(t.String(), "main.", "", -1)
+}
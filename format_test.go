@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTemplateFields(t *testing.T) {
+	fields := strings.Join(templateFields(reflect.TypeOf(mergeRequest{}), "", 0), "\n")
+	for _, expected := range []string{".Iid int", ".Author.Username string", ".HeadPipeline.Status string", ".IsDraft bool"} {
+		if !strings.Contains(fields, expected+"\n") {
+			t.Fatalf("Expected field %s in:\n%s\n", expected, fields)
+		}
+	}
+}
+
+func TestTemplateFuncs(t *testing.T) {
+	tmpl, err := newMonochromeTemplate("test", `{{ .Title | truncate 8 | pad 9 }}|{{ itoa .Iid | padLeft 3 }}|{{ upper .State }}|{{ colorIf .HasConflicts "red" "conflict" }}|{{ link .WebUrl "!7" }}`)
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	err = tmpl.Execute(&out, mergeRequest{Iid: 7, Title: "Add merge request listing", State: "opened", HasConflicts: true, WebUrl: "https://gitlab.example.com/mr/7"})
+	if nil != err {
+		t.Fatal(err)
+	}
+	if out.String() != "Add mer… |  7|OPENED|conflict|!7" {
+		t.Fatal("Unexpected output:", out.String())
+	}
+}
+
+func TestRenderMarkdownMonochrome(t *testing.T) {
+	got := renderMarkdown("## Changes\nUse `lab mr list`, see [docs](https://example.com/docs)", false)
+	if got != "Changes\nUse lab mr list, see docs (https://example.com/docs)" {
+		t.Fatal("Unexpected markdown:", got)
+	}
+}
+
+func TestDuration(t *testing.T) {
+	for seconds, expected := range map[float64]string{
+		45.7: "45s",
+		185:  "3m 05s",
+		3720: "1h 02m",
+	} {
+		if got := duration(seconds); got != expected {
+			t.Fatalf("Expected %v seconds as %s, got: %s\n", seconds, expected, got)
+		}
+	}
+}
+
+func TestPadWide(t *testing.T) {
+	if got := pad(6, "修正"); got != "修正  " {
+		t.Fatalf("Expected padding to the width of wide characters, got: %q\n", got)
+	}
+	if got := padLeft(3, "修"); got != " 修" {
+		t.Fatalf("Expected padding to the width of wide characters, got: %q\n", got)
+	}
+}
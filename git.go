@@ -6,6 +6,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"syscall"
 )
 
 type gitRemote struct {
@@ -41,89 +42,208 @@ func getRemoteUrlFromRemoteVOutput(remoteName string, output []byte) (string, er
 	return "", ErrUnknownRemote(remoteName)
 }
 
-func (here gitDir) checkout(arg string) error {
-	// Get working directory
-	wd, err := here.Getwd()
-	if nil != err {
-		return err
+func getRemoteNameFromRemoteVOutput(remoteUrl string, output []byte) (string, error) {
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && fields[1] == remoteUrl {
+			return fields[0], nil
+		}
 	}
 
-	// Fetch first
-	fetchCmd := exec.Command("git", "fetch")
-	fetchCmd.Dir = wd
-	fetchCmd.Stdout = os.Stdout
-	fetchCmd.Stdin = os.Stdin
-	err = fetchCmd.Run()
+	return "", ErrUnknownRemote(remoteUrl)
+}
+
+/// Run git in the working directory, output goes to stderr
+func (here gitDir) run(args ...string) error {
+	// Get working directory
+	wd, err := here.Getwd()
 	if nil != err {
 		return err
 	}
 
-	cmd := exec.Command("git", "checkout", "origin/"+arg)
+	cmd := exec.Command("git", args...)
 	cmd.Dir = wd
-	cmd.Stdout = os.Stdout
+	cmd.Stdout = os.Stderr
 	cmd.Stderr = os.Stderr
 	return cmd.Run()
 }
 
-func (here gitDir) diff2(left, right string) error {
+/// Run git in the working directory and get its trimmed output
+func (here gitDir) output(args ...string) (string, error) {
 	// Get working directory
 	wd, err := here.Getwd()
 	if nil != err {
-		return err
+		return "", err
 	}
 
-	remotes := map[string]bool{"origin": true}
+	cmd := exec.Command("git", args...)
+	cmd.Dir = wd
+	output, err := cmd.Output()
+	return strings.TrimSpace(string(output)), err
+}
 
-	// Left remote?
-	if strings.Contains(left, "/") {
-		leftRemote := strings.Split(left, "/")[0]
-		remotes[leftRemote] = true
-	} else {
-		left = "origin/" + left
+/// Tell whether git accepts name for a branch, eg. no "..", leading "-" or trailing ".lock"
+func isValidBranchName(name string) bool {
+	if strings.HasPrefix(name, "-") || strings.HasPrefix(name, "@{") {
+		// Options and previous branches like "@{-1}" are no names of their own
+		return false
 	}
+	return nil == exec.Command("git", "check-ref-format", "--branch", name).Run()
+}
 
-	if strings.Contains(right, "/") {
-		rightRemote := strings.Split(right, "/")[0]
-		remotes[rightRemote] = true
-	} else {
-		right = "origin/" + right
+func (here gitDir) revParse(ref string) (string, error) {
+	return here.output("rev-parse", "--verify", "--quiet", ref+"^{commit}")
+}
+
+func (here gitDir) hasBranch(branch string) bool {
+	_, err := here.revParse("refs/heads/" + branch)
+	return nil == err
+}
+
+/// Create branch at sha, or fast-forward it if it exists
+func (here gitDir) createOrFastForwardBranch(branch, sha string) error {
+	if !here.hasBranch(branch) {
+		return here.run("branch", branch, sha)
+	}
+
+	err := here.run("merge-base", "--is-ancestor", "refs/heads/"+branch, sha)
+	if nil != err {
+		return fmt.Errorf("Local branch %s has diverged from the merge request\n", branch)
+	}
+
+	currentBranch, err := here.output("symbolic-ref", "--quiet", "--short", "HEAD")
+	if nil == err && currentBranch == branch {
+		return here.run("merge", "--ff-only", sha)
+	}
+
+	return here.run("update-ref", "refs/heads/"+branch, sha)
+}
+
+/// Name of the remote with the given url, or add it under the given name
+func (here gitDir) ensureRemote(name, remoteUrl string) (string, error) {
+	output, err := here.output("remote", "-v")
+	if nil != err {
+		return "", err
+	}
+
+	if existing, err := getRemoteNameFromRemoteVOutput(remoteUrl, []byte(output)); nil == err {
+		return existing, nil
+	}
+
+	if _, err := getRemoteUrlFromRemoteVOutput(name, []byte(output)); nil == err {
+		return "", fmt.Errorf("Remote %s exists, but does not point to %s\n", name, remoteUrl)
+	}
+
+	return name, here.run("remote", "add", name, remoteUrl)
+}
+
+/// Check out merge request head as a local branch (empty for a detached HEAD), optionally into a new worktree
+func (here gitDir) checkoutMergeRequest(branch, sha, worktree string) error {
+	if worktree == "" {
+		if branch == "" {
+			return here.run("checkout", "--detach", sha)
+		}
+		return here.run("checkout", branch)
+	}
+
+	if branch == "" {
+		return here.run("worktree", "add", "--detach", worktree, sha)
+	}
+	return here.run("worktree", "add", worktree, branch)
+}
+
+/// Fetch head of a merge request from the target project, works for forks too
+func (here gitDir) fetchMergeRequest(remote string, iid int, shas ...string) error {
+	// Get working directory
+	wd, err := here.Getwd()
+	if nil != err {
+		return err
+	}
+
+	// Keep stdout clean for diffs
+	fetchCmd := exec.Command("git", "fetch", remote, fmt.Sprintf("refs/merge-requests/%d/head", iid))
+	fetchCmd.Dir = wd
+	fetchCmd.Stdout = os.Stderr
+	fetchCmd.Stderr = os.Stderr
+	err = fetchCmd.Run()
+	if nil != err {
+		return err
 	}
 
-	for remote, _ := range remotes {
-		// Fetch first
-		fetchCmd := exec.Command("git", "fetch", remote)
+	// Commits of older versions may no longer be reachable from the head
+	for _, sha := range shas {
+		if here.hasCommit(sha) {
+			continue
+		}
+		fetchCmd := exec.Command("git", "fetch", remote, sha)
 		fetchCmd.Dir = wd
-		fetchCmd.Stdout = os.Stdout
-		fetchCmd.Stdin = os.Stdin
+		fetchCmd.Stdout = os.Stderr
+		fetchCmd.Stderr = os.Stderr
 		err = fetchCmd.Run()
 		if nil != err {
 			return err
 		}
 	}
 
-	cmd := exec.Command("git", "diff", left+".."+right, "--")
+	return nil
+}
+
+func (here gitDir) hasCommit(sha string) bool {
+	cmd := exec.Command("git", "--git-dir", string(here), "cat-file", "-e", sha+"^{commit}")
+	return nil == cmd.Run()
+}
+
+/// Diff between two commits, extra args like "--stat" go before the commits
+func (here gitDir) diff(base, head string, args ...string) error {
+	// Get working directory
+	wd, err := here.Getwd()
+	if nil != err {
+		return err
+	}
+
+	// Paging is up to lab, see startPager
+	args = append([]string{"--no-pager", "diff"}, args...)
+	cmd := exec.Command("git", append(args, base, head, "--")...)
 	cmd.Dir = wd
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	return cmd.Run()
 }
 
-func (here gitDir) getCurrentBranch() (string, error) {
-	cmd := exec.Command("git", "--git-dir", string(here), "name-rev", "--name-only", "HEAD")
-	output, err := cmd.CombinedOutput()
-
+/// Open diff between two commits in an external tool, the configured diff.tool if tool is empty
+func (here gitDir) difftool(base, head, tool string) error {
+	// Get working directory
+	wd, err := here.Getwd()
 	if nil != err {
-		return "", err
+		return err
 	}
 
-	ref := strings.Trim(string(output), "\n\t ")
+	args := []string{"difftool", "--no-prompt"}
+	if tool != "" {
+		args = append(args, "--tool="+tool)
+	}
+	cmd := exec.Command("git", append(args, base, head, "--")...)
+	cmd.Dir = wd
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
 
-	// If ref name, eg. remotes/origin/my-branch
-	if strings.Contains(ref, "/") {
-		refParts := strings.Split(ref, "/")
-		ref = refParts[len(refParts)-1]
+/// Name of the checked out branch, eg. "feature/login", fails on a detached HEAD
+func (here gitDir) getCurrentBranch() (string, error) {
+	cmd := exec.Command("git", "--git-dir", string(here), "symbolic-ref", "--quiet", "--short", "HEAD")
+	output, err := cmd.Output()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.ExitStatus() == 1 {
+			return "", fmt.Errorf("Not on a branch, HEAD is detached: check one out, or give what to use as argument\n")
+		}
 	}
-	return ref, nil
+	if nil != err {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(output)), nil
 }
 
 func parseRemote(remoteAddr string) (remote gitRemote) {
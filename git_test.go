@@ -1,6 +1,10 @@
 package main
 
 import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
 	"testing"
 )
 
@@ -62,3 +66,54 @@ func TestParseGitHttpRemote(t *testing.T) {
 		t.Fatal("Expected remote path: \"someday/somewhere\", got:", remote.path)
 	}
 }
+
+func TestGetRemoteNameByUrl(t *testing.T) {
+	output := []byte(`
+origin	git@gitlab.com:someday/somewhere.git (fetch)
+origin	git@gitlab.com:someday/somewhere.git (push)
+fork	git@gitlab.com:alice/somewhere.git (fetch)
+`)
+
+	name, err := getRemoteNameFromRemoteVOutput("git@gitlab.com:alice/somewhere.git", output)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if name != "fork" {
+		t.Fatal("Expected remote: \"fork\", got:", name)
+	}
+
+	_, err = getRemoteNameFromRemoteVOutput("git@gitlab.com:bob/somewhere.git", output)
+	if _, ok := err.(ErrUnknownRemote); !ok {
+		t.Fatal("Expected ErrUnknownRemote, got:", err)
+	}
+}
+
+func TestGetCurrentBranch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lab-git")
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=lab", "-c", "user.email=lab@example.com"}, args...)...)
+		cmd.Dir = dir
+		if output, err := cmd.CombinedOutput(); nil != err {
+			t.Fatal(string(output), err)
+		}
+	}
+	git("init", "--quiet")
+	git("checkout", "--quiet", "-b", "feature/login")
+	git("commit", "--quiet", "--allow-empty", "-m", "Login")
+
+	here := gitDir(filepath.Join(dir, ".git"))
+	branch, err := here.getCurrentBranch()
+	if nil != err || branch != "feature/login" {
+		t.Fatal("Expected branch feature/login, got:", branch, err)
+	}
+
+	git("checkout", "--quiet", "--detach")
+	if branch, err = here.getCurrentBranch(); nil == err {
+		t.Fatal("Expected error on detached HEAD, got:", branch)
+	}
+}
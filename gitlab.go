@@ -3,6 +3,7 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"encoding/xml"
 	"errors"
 	"fmt"
 	"io/ioutil"
@@ -20,12 +21,178 @@ type mergeRequest struct {
 	Description  string `json:"description"`
 	SourceBranch string `json:"source_branch"`
 	TargetBranch string `json:"target_branch"`
+
+	ProjectId       int         `json:"project_id"`
+	SourceProjectId int         `json:"source_project_id"`
+	TargetProjectId int         `json:"target_project_id"`
+	State           string      `json:"state"`
+	Draft           bool        `json:"draft"`
+	WorkInProgress  bool        `json:"work_in_progress"`
+	WebUrl          string      `json:"web_url"`
+	References      *references `json:"references"`
+	Labels          []string    `json:"labels"`
+	Milestone       *milestone  `json:"milestone"`
+	Upvotes         int         `json:"upvotes"`
+	Downvotes       int         `json:"downvotes"`
+	UserNotesCount  int         `json:"user_notes_count"`
+	ChangesCount    string      `json:"changes_count"`
+
+	Author    *user  `json:"author"`
+	Assignee  *user  `json:"assignee"`
+	Assignees []user `json:"assignees"`
+	Reviewers []user `json:"reviewers"`
+	MergedBy  *user  `json:"merged_by"`
+	ClosedBy  *user  `json:"closed_by"`
+
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	MergedAt  *time.Time `json:"merged_at"`
+	ClosedAt  *time.Time `json:"closed_at"`
+
+	MergeStatus                 string    `json:"merge_status"`
+	DetailedMergeStatus         string    `json:"detailed_merge_status"`
+	HasConflicts                bool      `json:"has_conflicts"`
+	BlockingDiscussionsResolved bool      `json:"blocking_discussions_resolved"`
+	DiscussionLocked            bool      `json:"discussion_locked"`
+	MergeWhenPipelineSucceeds   bool      `json:"merge_when_pipeline_succeeds"`
+	ShouldRemoveSourceBranch    bool      `json:"should_remove_source_branch"`
+	ForceRemoveSourceBranch     bool      `json:"force_remove_source_branch"`
+	Squash                      bool      `json:"squash"`
+	Sha                         string    `json:"sha"`
+	MergeCommitSha              string    `json:"merge_commit_sha"`
+	SquashCommitSha             string    `json:"squash_commit_sha"`
+	Pipeline                    *pipeline `json:"pipeline"`
+	HeadPipeline                *pipeline `json:"head_pipeline"`
+	DiffRefs                    *diffRefs `json:"diff_refs"`
+
+	TimeStats            *timeStats            `json:"time_stats"`
+	TaskCompletionStatus *taskCompletionStatus `json:"task_completion_status"`
+
+	// Not part of the merge request payload, see loadApprovals
+	Approvals *mergeRequestApprovals `json:"approvals,omitempty"`
+	// Not part of the merge request payload, set when listing across projects
+	ProjectPath string `json:"project_path,omitempty"`
+}
+
+type references struct {
+	Short    string `json:"short"`
+	Relative string `json:"relative"`
+	Full     string `json:"full"`
+}
+
+type user struct {
+	Id        int    `json:"id"`
+	Username  string `json:"username"`
+	Name      string `json:"name"`
+	State     string `json:"state,omitempty"`
+	AvatarUrl string `json:"avatar_url,omitempty"`
+	WebUrl    string `json:"web_url,omitempty"`
+}
+
+type milestone struct {
+	Id          int    `json:"id"`
+	Iid         int    `json:"iid"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	State       string `json:"state"`
+	DueDate     string `json:"due_date"`
+	WebUrl      string `json:"web_url"`
+}
+
+type timeStats struct {
+	TimeEstimate        int    `json:"time_estimate"`
+	TotalTimeSpent      int    `json:"total_time_spent"`
+	HumanTimeEstimate   string `json:"human_time_estimate"`
+	HumanTotalTimeSpent string `json:"human_total_time_spent"`
+}
+
+type taskCompletionStatus struct {
+	Count          int `json:"count"`
+	CompletedCount int `json:"completed_count"`
+}
+
+type pipeline struct {
+	Id        int    `json:"id"`
+	Iid       int    `json:"iid"`
+	ProjectId int    `json:"project_id"`
+	Sha       string `json:"sha"`
+	Ref       string `json:"ref"`
+	Tag       bool   `json:"tag"`
+	Status    string `json:"status"`
+	Source    string `json:"source"`
+	WebUrl    string `json:"web_url"`
+	User      *user  `json:"user"`
+
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	StartedAt      *time.Time `json:"started_at"`
+	FinishedAt     *time.Time `json:"finished_at"`
+	Duration       float64    `json:"duration"`
+	QueuedDuration float64    `json:"queued_duration"`
+	Coverage       string     `json:"coverage"`
+	YamlErrors     string     `json:"yaml_errors"`
+}
+
+type project struct {
+	Id                int       `json:"id"`
+	Name              string    `json:"name"`
+	Path              string    `json:"path"`
+	PathWithNamespace string    `json:"path_with_namespace"`
+	DefaultBranch     string    `json:"default_branch"`
+	SshUrlToRepo      string    `json:"ssh_url_to_repo"`
+	HttpUrlToRepo     string    `json:"http_url_to_repo"`
+	WebUrl            string    `json:"web_url"`
+	Namespace         namespace `json:"namespace"`
+}
+
+type namespace struct {
+	Id       int    `json:"id"`
+	Name     string `json:"name"`
+	Path     string `json:"path"`
+	Kind     string `json:"kind"`
+	FullPath string `json:"full_path"`
+}
+
+type diffRefs struct {
+	BaseSha  string `json:"base_sha"`
+	HeadSha  string `json:"head_sha"`
+	StartSha string `json:"start_sha"`
+}
+
+type mergeRequestApprovals struct {
+	Approved           bool       `json:"approved"`
+	ApprovalsRequired  int        `json:"approvals_required"`
+	ApprovalsLeft      int        `json:"approvals_left"`
+	ApprovedBy         []approver `json:"approved_by"`
+	Approvers          []approver `json:"approvers"`
+	SuggestedApprovers []user     `json:"suggested_approvers"`
+	UserHasApproved    bool       `json:"user_has_approved"`
+	UserCanApprove     bool       `json:"user_can_approve"`
+}
+
+type approver struct {
+	User user `json:"user"`
+}
+
+type mergeRequestApproveRequest struct {
+	Sha string `json:"sha,omitempty"`
+}
+
+type mergeRequestAcceptRequest struct {
+	MergeCommitMessage        string `json:"merge_commit_message,omitempty"`
+	ShouldRemoveSourceBranch  bool   `json:"should_remove_source_branch,omitempty"`
+	MergeWhenPipelineSucceeds bool   `json:"merge_when_pipeline_succeeds,omitempty"`
+	Sha                       string `json:"sha,omitempty"`
+	Squash                    bool   `json:"squash,omitempty"`
 }
 
 type mergeRequestCreateRequest struct {
 	SourceBranch string `json:"source_branch"`
 	TargetBranch string `json:"target_branch"`
 	Title        string `json:"title"`
+	Description  string `json:"description,omitempty"`
+	Labels       string `json:"labels,omitempty"`
+	MilestoneId  int    `json:"milestone_id,omitempty"`
 }
 
 type session struct {
@@ -58,17 +225,27 @@ type gitlab struct {
 }
 
 type activityFeed struct {
-	Title   string        `xml:"title"`
-	Entries []*feedCommit `xml:"entry"`
+	Title   string        `xml:"title" json:"title"`
+	Entries []*feedCommit `xml:"entry" json:"entries"`
 }
 
 type feedCommit struct {
-	Title   string    `xml:"title"`
-	Updated time.Time `xml:"updated"`
+	Title   string    `xml:"title" json:"title"`
+	Updated time.Time `xml:"updated" json:"updated"`
+	Link    feedLink  `xml:"link" json:"link"`
+}
+
+type feedLink struct {
+	Href string `xml:"href,attr" json:"href"`
 }
 
 const MERGE_REQUEST_STATE_OPENED string = "opened"
+const PIPELINE_STATUS_FAILED string = "failed"
+const MERGE_STATUS_CANNOT_BE_MERGED string = "cannot_be_merged"
 const DASHBOARD_FEED_PATH string = "/dashboard.atom"
+const PAGE_SIZE int = 100
+
+var ErrStopPaging = errors.New("Stop paging")
 
 func (g gitlab) getProjectUrl(path string) string {
 	return g.scheme + "://" + g.host + "/" + strings.TrimPrefix(path, "/")
@@ -81,7 +258,7 @@ func (g gitlab) getMergeRequestUrl(projectId string, mergeRequestId int) string
 }
 
 func newGitlab(host string) gitlab {
-	return gitlab{"http", host, "/api/v3", ""}
+	return gitlab{"http", host, "/api/v4", ""}
 }
 
 func (g gitlab) getPrivateTokenUrl() string {
@@ -92,6 +269,11 @@ func (g gitlab) getFeedUrl() string {
 	return g.scheme + "://" + g.host + DASHBOARD_FEED_PATH + "?private_token=" + g.token
 }
 
+/// Activity feed of a project
+func (g gitlab) getProjectFeedUrl(path string) string {
+	return g.getProjectUrl(path) + ".atom?private_token=" + g.token
+}
+
 func (g gitlab) buildFeed(method, url string, body []byte) ([]byte, error) {
 	var req *http.Request
 	var err error
@@ -130,6 +312,23 @@ func (g gitlab) buildFeed(method, url string, body []byte) ([]byte, error) {
 	return contents, err
 }
 
+/// Get and decode an atom feed, eg. of getFeedUrl
+func (g gitlab) getActivityFeed(feedUrl string) (*activityFeed, error) {
+	contents, err := g.buildFeed("GET", feedUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var activity activityFeed
+	sanitizedContents := strings.Replace(string(contents), "<img", "&lt;img", -1)
+	err = xml.Unmarshal([]byte(sanitizedContents), &activity)
+	if err != nil {
+		return nil, err
+	}
+
+	return &activity, nil
+}
+
 func (g gitlab) getApiUrl(pathSegments ...string) string {
 	return g.getUnauthApiUrl(pathSegments...) + "?private_token=" + g.token
 }
@@ -146,13 +345,7 @@ func (g gitlab) getUnauthOpaqueApiUrl(pathSegments ...string) string {
 	return "//" + g.host + g.apiPath + "/" + strings.Join(pathSegments, "/")
 }
 
-func (g gitlab) createMergeRequest(projectId, sourceBranch, targetBranch, title string) (*mergeRequest, error) {
-	requestBody := mergeRequestCreateRequest{
-		SourceBranch: sourceBranch,
-		TargetBranch: targetBranch,
-		Title:        title,
-	}
-
+func (g gitlab) createMergeRequest(projectId string, requestBody mergeRequestCreateRequest) (*mergeRequest, error) {
 	buffer := &bytes.Buffer{}
 	encoder := json.NewEncoder(buffer)
 	err := encoder.Encode(requestBody)
@@ -179,7 +372,7 @@ func (g gitlab) createMergeRequest(projectId, sourceBranch, targetBranch, title
 
 	if resp.StatusCode == 404 {
 		// Duplicate merge request, same source branch
-		return nil, fmt.Errorf("There already exists a merge request for: %s\n", sourceBranch)
+		return nil, fmt.Errorf("There already exists a merge request for: %s\n", requestBody.SourceBranch)
 	}
 
 	if resp.StatusCode != 201 {
@@ -276,93 +469,316 @@ func (g gitlab) getSession(login, password string) (*session, error) {
 	return &session, nil
 }
 
-func (g gitlab) queryMergeRequests(projectId string, state string) ([]mergeRequest, error) {
-	if state == "" {
-		state = MERGE_REQUEST_STATE_OPENED
+/// Query merge requests of a project, filtered by api parameters, eg. "state" or "labels"
+func (g gitlab) queryMergeRequests(projectId string, query url.Values) ([]mergeRequest, error) {
+	var mergeRequests []mergeRequest
+	err := g.eachMergeRequestPage(projectId, query, func(page []mergeRequest) error {
+		mergeRequests = append(mergeRequests, page...)
+		return nil
+	})
+
+	return mergeRequests, err
+}
+
+/// Query merge requests of a project page by page
+func (g gitlab) eachMergeRequestPage(projectId string, query url.Values, each func([]mergeRequest) error) error {
+	if query.Get("state") == "" {
+		query.Set("state", MERGE_REQUEST_STATE_OPENED)
 	}
-	addr := g.getApiUrl("projects", url.QueryEscape(projectId), "merge_requests") + "&state=" + state
 
-	req, err := http.NewRequest("GET", addr, nil)
-	req.URL = &url.URL{
-		Scheme: g.scheme,
-		Host:   g.host,
-		// Use opaque url to preserve "%2F"
-		Opaque: g.getOpaqueApiUrl("projects", url.QueryEscape(projectId), "merge_requests") + "&state=" + state,
+	return g.eachPage(query, func(resp *http.Response) error {
+		var page []mergeRequest
+		err := g.decodeApiResponse(resp, 200, &page)
+		if nil != err {
+			return err
+		}
+		return each(page)
+	}, "projects", url.QueryEscape(projectId), "merge_requests")
+}
+
+/// Query merge requests across all projects, or those of a group when groupId is given
+func (g gitlab) queryGlobalMergeRequests(groupId string, query url.Values) ([]mergeRequest, error) {
+	var mergeRequests []mergeRequest
+	err := g.eachGlobalMergeRequestPage(groupId, query, func(page []mergeRequest) error {
+		mergeRequests = append(mergeRequests, page...)
+		return nil
+	})
+
+	return mergeRequests, err
+}
+
+/// Query merge requests across projects page by page, setting the project path of each
+func (g gitlab) eachGlobalMergeRequestPage(groupId string, query url.Values, each func([]mergeRequest) error) error {
+	if query.Get("state") == "" {
+		query.Set("state", MERGE_REQUEST_STATE_OPENED)
 	}
 
-	client := http.Client{}
-	resp, err := client.Do(req)
+	pathSegments := []string{"merge_requests"}
+	if groupId != "" {
+		pathSegments = []string{"groups", url.QueryEscape(groupId), "merge_requests"}
+	}
 
+	return g.eachPage(query, func(resp *http.Response) error {
+		var page []mergeRequest
+		err := g.decodeApiResponse(resp, 200, &page)
+		if nil != err {
+			return err
+		}
+		for i := range page {
+			page[i].ProjectPath = page[i].projectPathFromPayload()
+		}
+		return each(page)
+	}, pathSegments...)
+}
+
+/// Get the user owning the private token
+func (g gitlab) getCurrentUser() (*user, error) {
+	resp, err := g.doApiRequest("GET", "user")
 	if nil != err {
 		return nil, err
 	}
 
-	if resp.StatusCode == 404 {
-		if g.token != "" {
-			addr = strings.Replace(addr, g.token, "***", -1)
-		}
-		return nil, fmt.Errorf("404: %s\n", addr)
+	var u user
+	err = g.decodeApiResponse(resp, 200, &u)
+	if nil != err {
+		return nil, err
 	}
-	if resp.StatusCode != 200 {
-		return nil, g.getErrorFromResponse(resp, 200)
+
+	return &u, nil
+}
+
+/// Get project by numeric id or path
+func (g gitlab) getProject(projectId string) (*project, error) {
+	resp, err := g.doApiRequest("GET", "projects", url.QueryEscape(projectId))
+	if nil != err {
+		return nil, err
+	}
+
+	var p project
+	err = g.decodeApiResponse(resp, 200, &p)
+	if nil != err {
+		return nil, err
+	}
+
+	return &p, nil
+}
+
+/// Find a merge request of a project by its iid, as shown in urls and references, in the given state
+func (g gitlab) findMergeRequest(projectId string, state string, iid int) (*mergeRequest, error) {
+	resp, err := g.doApiRequestWithBody(
+		"GET",
+		url.Values{
+			"state":  {state},
+			"iids[]": {strconv.Itoa(iid)},
+		},
+		nil,
+		"projects",
+		url.QueryEscape(projectId),
+		"merge_requests",
+	)
+	if nil != err {
+		return nil, err
 	}
 
 	var mergeRequests []mergeRequest
-	d := json.NewDecoder(resp.Body)
+	err = g.decodeApiResponse(resp, 200, &mergeRequests)
+	if nil != err {
+		return nil, err
+	}
+
+	for i := range mergeRequests {
+		if mergeRequests[i].Iid == iid {
+			return &mergeRequests[i], nil
+		}
+	}
 
-	// Check for leading "<" -> "api 404"
-	err = d.Decode(&mergeRequests)
+	return nil, fmt.Errorf("Unable to find merge request with ID #%d\n", iid)
+}
+
+func (g gitlab) getMergeRequest(projectId string, mergeRequestIid int) (*mergeRequest, error) {
+	resp, err := g.doApiRequest(
+		"GET",
+		"projects",
+		url.QueryEscape(projectId),
+		"merge_requests",
+		strconv.Itoa(mergeRequestIid),
+	)
+	if nil != err {
+		return nil, err
+	}
 
+	var request mergeRequest
+	err = g.decodeApiResponse(resp, 200, &request)
 	if nil != err {
 		return nil, err
 	}
 
-	return mergeRequests, nil
+	return &request, nil
 }
 
-func (g gitlab) acceptMergeRequest(projectId string, mergeRequestId int) error {
+/// Get approval state of a merge request, nil if approvals are not available on the server
+func (g gitlab) getMergeRequestApprovals(projectId string, mergeRequestIid int) (*mergeRequestApprovals, error) {
+	resp, err := g.doApiRequest(
+		"GET",
+		"projects",
+		url.QueryEscape(projectId),
+		"merge_requests",
+		strconv.Itoa(mergeRequestIid),
+		"approvals",
+	)
+	if nil != err {
+		return nil, err
+	}
+
+	if resp.StatusCode == 404 {
+		// Approvals are an enterprise feature
+		resp.Body.Close()
+		return nil, nil
+	}
+
+	var approvals mergeRequestApprovals
+	err = g.decodeApiResponse(resp, 200, &approvals)
+	if nil != err {
+		return nil, err
+	}
+
+	return &approvals, nil
+}
+
+/// Fill in the approval state of each merge request, projectId is used when the target project is unknown
+func (g gitlab) loadApprovals(projectId string, requests []mergeRequest) error {
+	for i := range requests {
+		target := projectId
+		if requests[i].TargetProjectId != 0 {
+			target = strconv.Itoa(requests[i].TargetProjectId)
+		}
+		approvals, err := g.getMergeRequestApprovals(target, requests[i].Iid)
+		if nil != err {
+			return err
+		}
+		requests[i].Approvals = approvals
+	}
+
+	return nil
+}
 
-	addr := g.getApiUrl(
+/// Approve merge request, optionally only if the source branch HEAD matches sha
+func (g gitlab) approveMergeRequest(projectId string, mergeRequestIid int, sha string) (*mergeRequestApprovals, error) {
+	resp, err := g.doApiRequestWithBody(
+		"POST",
+		nil,
+		mergeRequestApproveRequest{Sha: sha},
 		"projects",
 		url.QueryEscape(projectId),
-		"merge_request",
-		url.QueryEscape(strconv.Itoa(mergeRequestId)),
-		"merge",
+		"merge_requests",
+		strconv.Itoa(mergeRequestIid),
+		"approve",
 	)
+	if nil != err {
+		return nil, err
+	}
 
-	req, err := http.NewRequest("PUT", addr, nil)
-	req.URL = &url.URL{
-		Scheme: g.scheme,
-		Host:   g.host,
-		// Use opaque url to preserve "%2F"
-		Opaque: g.getOpaqueApiUrl("projects", url.QueryEscape(projectId), "merge_request", strconv.Itoa(mergeRequestId), "merge"),
+	if resp.StatusCode == 401 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("You are not allowed to approve merge request !%d\n", mergeRequestIid)
 	}
 
-	client := http.Client{}
-	resp, err := client.Do(req)
+	var approvals mergeRequestApprovals
+	err = g.decodeApiResponse(resp, 201, &approvals)
+	if nil != err {
+		return nil, err
+	}
+
+	return &approvals, nil
+}
 
+/// Withdraw approval of merge request
+func (g gitlab) unapproveMergeRequest(projectId string, mergeRequestIid int) error {
+	resp, err := g.doApiRequest(
+		"POST",
+		"projects",
+		url.QueryEscape(projectId),
+		"merge_requests",
+		strconv.Itoa(mergeRequestIid),
+		"unapprove",
+	)
 	if nil != err {
 		return err
 	}
 
-	if resp.StatusCode == 404 {
-		if g.token != "" {
-			addr = strings.Replace(addr, g.token, "***", -1)
-		}
-		return fmt.Errorf("404: %s %s\n", req.Method, addr)
+	return g.decodeApiResponse(resp, 201, nil)
+}
+
+func (g gitlab) acceptMergeRequest(projectId string, mergeRequestIid int, options mergeRequestAcceptRequest) (*mergeRequest, error) {
+	resp, err := g.doApiRequestWithBody(
+		"PUT",
+		nil,
+		options,
+		"projects",
+		url.QueryEscape(projectId),
+		"merge_requests",
+		strconv.Itoa(mergeRequestIid),
+		"merge",
+	)
+	if nil != err {
+		return nil, err
 	}
 
-	if resp.StatusCode != 200 {
-		return g.getErrorFromResponse(resp, 200)
+	switch resp.StatusCode {
+	case 405:
+		resp.Body.Close()
+		return nil, fmt.Errorf("Merge request !%d cannot be merged\n", mergeRequestIid)
+	case 406:
+		resp.Body.Close()
+		return nil, fmt.Errorf("Merge request !%d is already merged or closed\n", mergeRequestIid)
+	case 409:
+		resp.Body.Close()
+		return nil, fmt.Errorf("SHA does not match HEAD of source branch: %s\n", options.Sha)
 	}
 
-	return nil
+	var merged mergeRequest
+	err = g.decodeApiResponse(resp, 200, &merged)
+	if nil != err {
+		return nil, err
+	}
+
+	return &merged, nil
 }
 
 func (g gitlab) doApiRequest(method string, pathSegments ...string) (*http.Response, error) {
+	return g.doApiRequestWithBody(method, nil, nil, pathSegments...)
+}
+
+/// Do api request with optional query parameters and json encoded body
+func (g gitlab) doApiRequestWithBody(method string, query url.Values, body interface{}, pathSegments ...string) (*http.Response, error) {
+	req, err := g.newApiRequest(method, query, body, pathSegments...)
+	if nil != err {
+		return nil, err
+	}
+
+	client := http.Client{}
+	return client.Do(req)
+}
+
+/// Build api request with optional query parameters and json encoded body, eg. to add headers before doing it
+func (g gitlab) newApiRequest(method string, query url.Values, body interface{}, pathSegments ...string) (*http.Request, error) {
 	addr := g.getApiUrl(pathSegments...)
+	opaque := g.getOpaqueApiUrl(pathSegments...)
+	if len(query) > 0 {
+		addr += "&" + query.Encode()
+		opaque += "&" + query.Encode()
+	}
 
-	req, err := http.NewRequest(method, addr, nil)
+	buffer := &bytes.Buffer{}
+	if nil != body {
+		encoder := json.NewEncoder(buffer)
+		err := encoder.Encode(body)
+		if nil != err {
+			return nil, err
+		}
+	}
+
+	req, err := http.NewRequest(method, addr, buffer)
 
 	if nil != err {
 		return nil, err
@@ -371,11 +787,69 @@ func (g gitlab) doApiRequest(method string, pathSegments ...string) (*http.Respo
 		Scheme: g.scheme,
 		Host:   g.host,
 		// Use opaque url to preserve "%2F"
-		Opaque: g.getOpaqueApiUrl(pathSegments...),
+		Opaque: opaque,
+	}
+	if nil != body {
+		req.Header.Set("Content-Type", "application/json")
 	}
 
-	client := http.Client{}
-	return client.Do(req)
+	return req, nil
+}
+
+/// Get every page of a list endpoint, each may return ErrStopPaging to stop early
+func (g gitlab) eachPage(query url.Values, each func(*http.Response) error, pathSegments ...string) error {
+	paged := url.Values{}
+	for key, values := range query {
+		paged[key] = values
+	}
+	if paged.Get("per_page") == "" {
+		paged.Set("per_page", strconv.Itoa(PAGE_SIZE))
+	}
+
+	for page := "1"; page != ""; {
+		if page != "1" {
+			paged.Set("page", page)
+		}
+
+		resp, err := g.doApiRequestWithBody("GET", paged, nil, pathSegments...)
+		if nil != err {
+			return err
+		}
+
+		page = resp.Header.Get("X-Next-Page")
+		err = each(resp)
+		if err == ErrStopPaging {
+			return nil
+		}
+		if nil != err {
+			return err
+		}
+	}
+
+	return nil
+}
+
+/// Check status code of an api response and decode the json body into out, when given
+func (g gitlab) decodeApiResponse(resp *http.Response, expectedStatusCode int, out interface{}) error {
+	defer resp.Body.Close()
+
+	if resp.StatusCode == 404 {
+		addr := resp.Request.URL.Opaque
+		if g.token != "" {
+			addr = strings.Replace(addr, g.token, "***", -1)
+		}
+		return fmt.Errorf("404: %s %s\n", resp.Request.Method, addr)
+	}
+
+	if resp.StatusCode != expectedStatusCode {
+		return g.getErrorFromResponse(resp, expectedStatusCode)
+	}
+
+	if nil == out {
+		return nil
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
 }
 
 func (g gitlab) removeBranch(projectId string, branch string) error {
@@ -405,3 +879,95 @@ func (g gitlab) removeBranch(projectId string, branch string) error {
 
 	return nil
 }
+
+/// Reasons for refusing to accept a merge request, empty if it looks mergeable
+func (r mergeRequest) mergeBlockers() []string {
+	var blockers []string
+
+	if r.HeadPipeline != nil && r.HeadPipeline.Status == PIPELINE_STATUS_FAILED {
+		blockers = append(blockers, fmt.Sprintf("Pipeline #%d failed", r.HeadPipeline.Id))
+	}
+
+	if r.HasConflicts || r.MergeStatus == MERGE_STATUS_CANNOT_BE_MERGED {
+		blockers = append(blockers, "Merge request has conflicts")
+	}
+
+	if r.Approvals != nil && r.Approvals.ApprovalsLeft > 0 {
+		blockers = append(blockers, fmt.Sprintf(
+			"Missing %d of %d required approvals",
+			r.Approvals.ApprovalsLeft,
+			r.Approvals.ApprovalsRequired,
+		))
+	}
+
+	return blockers
+}
+
+/// Users allowed to approve, explicit approvers first, then suggested ones
+func (a mergeRequestApprovals) EligibleApprovers() []user {
+	seen := make(map[int]bool)
+	var users []user
+	for _, approver := range a.Approvers {
+		if !seen[approver.User.Id] {
+			seen[approver.User.Id] = true
+			users = append(users, approver.User)
+		}
+	}
+	for _, suggested := range a.SuggestedApprovers {
+		if !seen[suggested.Id] {
+			seen[suggested.Id] = true
+			users = append(users, suggested)
+		}
+	}
+
+	return users
+}
+
+/// Merge request from another project than its target
+func (r mergeRequest) fromFork() bool {
+	return r.SourceProjectId != 0 && r.SourceProjectId != r.TargetProjectId
+}
+
+/// Path of the target project, eg. "group/project", from the references or web url
+func (r mergeRequest) projectPathFromPayload() string {
+	if r.References != nil && strings.Contains(r.References.Full, "!") {
+		return r.References.Full[:strings.LastIndex(r.References.Full, "!")]
+	}
+
+	u, err := url.Parse(r.WebUrl)
+	if nil != err || u.Path == "" {
+		return ""
+	}
+	path := strings.Trim(u.Path, "/")
+	for _, separator := range []string{"/-/merge_requests/", "/merge_requests/"} {
+		if i := strings.LastIndex(path, separator); i >= 0 {
+			return path[:i]
+		}
+	}
+
+	return ""
+}
+
+/// Status of the head pipeline, or the pipeline given in merge request lists
+func (r mergeRequest) PipelineStatus() string {
+	if r.HeadPipeline != nil {
+		return r.HeadPipeline.Status
+	}
+	if r.Pipeline != nil {
+		return r.Pipeline.Status
+	}
+	return ""
+}
+
+/// Marked as draft, also by older servers using "work in progress"
+func (r mergeRequest) IsDraft() bool {
+	return r.Draft || r.WorkInProgress
+}
+
+/// Assignees, also from older servers only having a single assignee
+func (r mergeRequest) AllAssignees() []user {
+	if len(r.Assignees) == 0 && r.Assignee != nil {
+		return []user{*r.Assignee}
+	}
+	return r.Assignees
+}
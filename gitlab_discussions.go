@@ -0,0 +1,206 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type discussion struct {
+	Id             string `json:"id"`
+	IndividualNote bool   `json:"individual_note"`
+	Notes          []note `json:"notes"`
+}
+
+type note struct {
+	Id         int           `json:"id"`
+	Type       string        `json:"type"`
+	Body       string        `json:"body"`
+	Author     user          `json:"author"`
+	CreatedAt  time.Time     `json:"created_at"`
+	UpdatedAt  time.Time     `json:"updated_at"`
+	System     bool          `json:"system"`
+	Resolvable bool          `json:"resolvable"`
+	Resolved   bool          `json:"resolved"`
+	ResolvedBy *user         `json:"resolved_by"`
+	Position   *notePosition `json:"position"`
+}
+
+type notePosition struct {
+	BaseSha      string `json:"base_sha"`
+	StartSha     string `json:"start_sha"`
+	HeadSha      string `json:"head_sha"`
+	PositionType string `json:"position_type"`
+	OldPath      string `json:"old_path,omitempty"`
+	NewPath      string `json:"new_path,omitempty"`
+	OldLine      int    `json:"old_line,omitempty"`
+	NewLine      int    `json:"new_line,omitempty"`
+}
+
+type noteCreateRequest struct {
+	Body string `json:"body"`
+}
+
+/// Abbreviated discussion id, enough to pick a thread
+func (d discussion) ShortId() string {
+	if len(d.Id) > 8 {
+		return d.Id[:8]
+	}
+	return d.Id
+}
+
+/// Diff position of the thread, nil for general comments
+func (d discussion) Position() *notePosition {
+	if len(d.Notes) == 0 {
+		return nil
+	}
+	return d.Notes[0].Position
+}
+
+func (d discussion) Resolvable() bool {
+	for _, note := range d.Notes {
+		if note.Resolvable {
+			return true
+		}
+	}
+	return false
+}
+
+/// All resolvable notes of the thread are resolved
+func (d discussion) Resolved() bool {
+	for _, note := range d.Notes {
+		if note.Resolvable && !note.Resolved {
+			return false
+		}
+	}
+	return d.Resolvable()
+}
+
+/// System notes only, eg. "added 1 commit"
+func (d discussion) System() bool {
+	for _, note := range d.Notes {
+		if !note.System {
+			return false
+		}
+	}
+	return len(d.Notes) > 0
+}
+
+/// First line of the note, eg. for activity lists
+func (n note) Excerpt() string {
+	for _, line := range strings.Split(n.Body, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return line
+		}
+	}
+	return ""
+}
+
+/// Latest notes of all threads, oldest first
+func recentNotes(discussions []discussion, count int) []note {
+	var notes []note
+	for _, d := range discussions {
+		notes = append(notes, d.Notes...)
+	}
+	sort.SliceStable(notes, func(i, j int) bool {
+		return notes[i].CreatedAt.Before(notes[j].CreatedAt)
+	})
+	if len(notes) > count {
+		notes = notes[len(notes)-count:]
+	}
+	return notes
+}
+
+/// File and line the position refers to, eg. "main.go:42"
+func (p notePosition) Location() string {
+	path := p.NewPath
+	if path == "" {
+		path = p.OldPath
+	}
+
+	if p.NewLine > 0 {
+		return path + ":" + strconv.Itoa(p.NewLine)
+	}
+	if p.OldLine > 0 {
+		return path + ":" + strconv.Itoa(p.OldLine) + " (removed)"
+	}
+	return path
+}
+
+/// All threads of a merge request, over as many pages as there are
+func (g gitlab) getMergeRequestDiscussions(projectId string, mergeRequestIid int) ([]discussion, error) {
+	var discussions []discussion
+	err := g.eachPage(nil, func(resp *http.Response) error {
+		var page []discussion
+		err := g.decodeApiResponse(resp, 200, &page)
+		if nil != err {
+			return err
+		}
+		discussions = append(discussions, page...)
+		return nil
+	}, "projects", url.QueryEscape(projectId), "merge_requests", strconv.Itoa(mergeRequestIid), "discussions")
+	if nil != err {
+		return nil, err
+	}
+
+	return discussions, nil
+}
+
+func (g gitlab) createMergeRequestNote(projectId string, mergeRequestIid int, body string) (*note, error) {
+	resp, err := g.doApiRequestWithBody(
+		"POST",
+		nil,
+		noteCreateRequest{Body: body},
+		"projects",
+		url.QueryEscape(projectId),
+		"merge_requests",
+		strconv.Itoa(mergeRequestIid),
+		"notes",
+	)
+	if nil != err {
+		return nil, err
+	}
+
+	var created note
+	err = g.decodeApiResponse(resp, 201, &created)
+	if nil != err {
+		return nil, err
+	}
+
+	return &created, nil
+}
+
+/// Resolve or unresolve a discussion thread
+func (g gitlab) resolveMergeRequestDiscussion(projectId string, mergeRequestIid int, discussionId string, resolved bool) (*discussion, error) {
+	resp, err := g.doApiRequestWithBody(
+		"PUT",
+		url.Values{"resolved": {strconv.FormatBool(resolved)}},
+		nil,
+		"projects",
+		url.QueryEscape(projectId),
+		"merge_requests",
+		strconv.Itoa(mergeRequestIid),
+		"discussions",
+		url.QueryEscape(discussionId),
+	)
+	if nil != err {
+		return nil, err
+	}
+
+	if resp.StatusCode == 403 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("You are not allowed to resolve discussion: %s\n", discussionId)
+	}
+
+	var updated discussion
+	err = g.decodeApiResponse(resp, 200, &updated)
+	if nil != err {
+		return nil, err
+	}
+
+	return &updated, nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	. "github.com/smartystreets/goconvey/convey"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestGetMergeRequestDiscussions(t *testing.T) {
+	Convey("Given a gitlab server with discussions", t, func() {
+		var req *http.Request
+		discussions := []discussion{
+			discussion{
+				Id: "6a9c1750b37d513a43987b574953fceb50b03ce7",
+				Notes: []note{
+					note{Id: 1, Body: "Why?", Resolvable: true, Author: user{Username: "alice"}},
+					note{Id: 2, Body: "Because", Resolvable: true, Resolved: true, Author: user{Username: "bob"}},
+				},
+			},
+		}
+
+		sr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			req = r
+			page := []discussion{}
+			switch r.URL.Query().Get("page") {
+			case "":
+				w.Header().Set("X-Next-Page", "2")
+				page = discussions
+			case "2":
+				page = append(page, discussion{Id: "7b1d2e4f"})
+			}
+			err := json.NewEncoder(w).Encode(page)
+			if nil != err {
+				t.Fatal(err)
+			}
+		}))
+
+		u := urlMustParse(t, sr.URL)
+		g := newGitlab(u.Host)
+		g.token = "my-private-token"
+
+		Convey("When getting the discussions", func() {
+			gotten, err := g.getMergeRequestDiscussions("17", 13)
+
+			Convey("The threads of all pages should be decoded", func() {
+				So(err, ShouldBeNil)
+				So(req.URL.String(), ShouldEqual, fmt.Sprintf(
+					"http://%s/api/v4/projects/17/merge_requests/13/discussions?private_token=my-private-token&page=2&per_page=100",
+					u.Host,
+				))
+				So(gotten, ShouldHaveLength, 2)
+				So(gotten[1].ShortId(), ShouldEqual, "7b1d2e4f")
+				So(gotten[0].ShortId(), ShouldEqual, "6a9c1750")
+				So(gotten[0].Resolvable(), ShouldBeTrue)
+				So(gotten[0].Resolved(), ShouldBeFalse)
+			})
+		})
+	})
+}
+
+func TestFindDiscussion(t *testing.T) {
+	Convey("Given some discussions", t, func() {
+		discussions := []discussion{
+			discussion{Id: "abc1", Notes: []note{note{Resolvable: true}}},
+			discussion{Id: "abc2", Notes: []note{note{Resolvable: true}}},
+			discussion{Id: "def1", Notes: []note{note{}}},
+		}
+
+		Convey("An unambiguous prefix should find the discussion", func() {
+			d, err := findDiscussion(discussions, "abc2")
+			So(err, ShouldBeNil)
+			So(d.Id, ShouldEqual, "abc2")
+		})
+
+		Convey("Ambiguous, unknown and unresolvable discussions should fail", func() {
+			_, err := findDiscussion(discussions, "abc")
+			So(err, ShouldNotBeNil)
+			_, err = findDiscussion(discussions, "xyz")
+			So(err, ShouldNotBeNil)
+			_, err = findDiscussion(discussions, "def")
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestNotePositionLocation(t *testing.T) {
+	Convey("Given diff positions", t, func() {
+		So(notePosition{NewPath: "main.go", NewLine: 42}.Location(), ShouldEqual, "main.go:42")
+		So(notePosition{OldPath: "old.go", OldLine: 7}.Location(), ShouldEqual, "old.go:7 (removed)")
+	})
+}
+
+func TestRecentNotes(t *testing.T) {
+	Convey("Given threads with notes from different times", t, func() {
+		at := func(day int) time.Time {
+			return time.Date(2015, 12, day, 12, 0, 0, 0, time.UTC)
+		}
+		discussions := []discussion{
+			discussion{Notes: []note{note{Id: 1, CreatedAt: at(1)}, note{Id: 4, CreatedAt: at(4)}}},
+			discussion{Notes: []note{note{Id: 2, CreatedAt: at(2), Body: "\n  Looks good\nbut"}}},
+			discussion{Notes: []note{note{Id: 3, CreatedAt: at(3)}}},
+		}
+
+		Convey("The latest notes should be kept, oldest first", func() {
+			notes := recentNotes(discussions, 3)
+			So(notes, ShouldHaveLength, 3)
+			So(notes[0].Id, ShouldEqual, 2)
+			So(notes[1].Id, ShouldEqual, 3)
+			So(notes[2].Id, ShouldEqual, 4)
+			So(notes[0].Excerpt(), ShouldEqual, "Looks good")
+		})
+	})
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+type issue struct {
+	Id           int         `json:"id"`
+	Iid          int         `json:"iid"`
+	ProjectId    int         `json:"project_id"`
+	Title        string      `json:"title"`
+	Description  string      `json:"description"`
+	State        string      `json:"state"`
+	Confidential bool        `json:"confidential"`
+	WebUrl       string      `json:"web_url"`
+	References   *references `json:"references"`
+	Labels       []string    `json:"labels"`
+	Milestone    *milestone  `json:"milestone"`
+	DueDate      string      `json:"due_date"`
+
+	Upvotes        int `json:"upvotes"`
+	Downvotes      int `json:"downvotes"`
+	UserNotesCount int `json:"user_notes_count"`
+
+	Author    *user  `json:"author"`
+	Assignee  *user  `json:"assignee"`
+	Assignees []user `json:"assignees"`
+	ClosedBy  *user  `json:"closed_by"`
+
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	ClosedAt  *time.Time `json:"closed_at"`
+
+	TimeStats            *timeStats            `json:"time_stats"`
+	TaskCompletionStatus *taskCompletionStatus `json:"task_completion_status"`
+}
+
+/// Fields to create or update an issue, unset fields are left alone
+type issueRequest struct {
+	Title        string  `json:"title,omitempty"`
+	Description  *string `json:"description,omitempty"`
+	Labels       *string `json:"labels,omitempty"`
+	AssigneeIds  []int   `json:"assignee_ids,omitempty"`
+	MilestoneId  *int    `json:"milestone_id,omitempty"`
+	Confidential *bool   `json:"confidential,omitempty"`
+	StateEvent   string  `json:"state_event,omitempty"`
+}
+
+const ISSUE_STATE_EVENT_CLOSE string = "close"
+const ISSUE_STATE_EVENT_REOPEN string = "reopen"
+
+func (g gitlab) getIssueUrl(projectId string, issueId int) string {
+	return g.getProjectIssuesUrl(projectId) + "/" + strconv.Itoa(issueId)
+}
+
+func (g gitlab) getProjectIssuesUrl(projectId string) string {
+	projectId, _ = url.QueryUnescape(projectId)
+	return g.getProjectUrl(projectId) + "/issues"
+}
+
+/// Query issues of a project, filtered by api parameters, eg. "state" or "labels"
+func (g gitlab) queryIssues(projectId string, query url.Values) ([]issue, error) {
+	var issues []issue
+	err := g.eachIssuePage(projectId, query, func(page []issue) error {
+		issues = append(issues, page...)
+		return nil
+	})
+
+	return issues, err
+}
+
+/// Query issues of a project page by page
+func (g gitlab) eachIssuePage(projectId string, query url.Values, each func([]issue) error) error {
+	if query.Get("state") == "" {
+		query.Set("state", MERGE_REQUEST_STATE_OPENED)
+	}
+
+	return g.eachPage(query, func(resp *http.Response) error {
+		var page []issue
+		err := g.decodeApiResponse(resp, 200, &page)
+		if nil != err {
+			return err
+		}
+		return each(page)
+	}, "projects", url.QueryEscape(projectId), "issues")
+}
+
+/// Find an issue of a project by its iid, as shown in urls and references
+func (g gitlab) findIssue(projectId string, iid int) (*issue, error) {
+	issues, err := g.queryIssues(projectId, url.Values{
+		"state":  {"all"},
+		"iids[]": {strconv.Itoa(iid)},
+	})
+	if nil != err {
+		return nil, err
+	}
+
+	for i := range issues {
+		if issues[i].Iid == iid {
+			return &issues[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("Unable to find issue with ID #%d\n", iid)
+}
+
+func (g gitlab) getIssue(projectId string, issueIid int) (*issue, error) {
+	resp, err := g.doApiRequest(
+		"GET",
+		"projects",
+		url.QueryEscape(projectId),
+		"issues",
+		strconv.Itoa(issueIid),
+	)
+	if nil != err {
+		return nil, err
+	}
+
+	var i issue
+	err = g.decodeApiResponse(resp, 200, &i)
+	if nil != err {
+		return nil, err
+	}
+
+	return &i, nil
+}
+
+func (g gitlab) createIssue(projectId string, options issueRequest) (*issue, error) {
+	resp, err := g.doApiRequestWithBody(
+		"POST",
+		nil,
+		options,
+		"projects",
+		url.QueryEscape(projectId),
+		"issues",
+	)
+	if nil != err {
+		return nil, err
+	}
+
+	var created issue
+	err = g.decodeApiResponse(resp, 201, &created)
+	if nil != err {
+		return nil, err
+	}
+
+	return &created, nil
+}
+
+/// Update fields of an issue, or close and reopen it by options.StateEvent
+func (g gitlab) updateIssue(projectId string, issueIid int, options issueRequest) (*issue, error) {
+	resp, err := g.doApiRequestWithBody(
+		"PUT",
+		nil,
+		options,
+		"projects",
+		url.QueryEscape(projectId),
+		"issues",
+		strconv.Itoa(issueIid),
+	)
+	if nil != err {
+		return nil, err
+	}
+
+	var updated issue
+	err = g.decodeApiResponse(resp, 200, &updated)
+	if nil != err {
+		return nil, err
+	}
+
+	return &updated, nil
+}
+
+func (g gitlab) getIssueNotes(projectId string, issueIid int) ([]note, error) {
+	resp, err := g.doApiRequestWithBody(
+		"GET",
+		url.Values{"per_page": {"100"}},
+		nil,
+		"projects",
+		url.QueryEscape(projectId),
+		"issues",
+		strconv.Itoa(issueIid),
+		"notes",
+	)
+	if nil != err {
+		return nil, err
+	}
+
+	var notes []note
+	err = g.decodeApiResponse(resp, 200, &notes)
+	if nil != err {
+		return nil, err
+	}
+
+	return notes, nil
+}
+
+func (g gitlab) createIssueNote(projectId string, issueIid int, body string) (*note, error) {
+	resp, err := g.doApiRequestWithBody(
+		"POST",
+		nil,
+		noteCreateRequest{Body: body},
+		"projects",
+		url.QueryEscape(projectId),
+		"issues",
+		strconv.Itoa(issueIid),
+		"notes",
+	)
+	if nil != err {
+		return nil, err
+	}
+
+	var created note
+	err = g.decodeApiResponse(resp, 201, &created)
+	if nil != err {
+		return nil, err
+	}
+
+	return &created, nil
+}
+
+/// Get user by username, eg. to assign issues
+func (g gitlab) findUser(username string) (*user, error) {
+	resp, err := g.doApiRequestWithBody("GET", url.Values{"username": {username}}, nil, "users")
+	if nil != err {
+		return nil, err
+	}
+
+	var users []user
+	err = g.decodeApiResponse(resp, 200, &users)
+	if nil != err {
+		return nil, err
+	}
+
+	for _, u := range users {
+		if u.Username == username {
+			return &u, nil
+		}
+	}
+
+	return nil, fmt.Errorf("Unknown user: %s\n", username)
+}
+
+/// Get milestone of a project by title
+func (g gitlab) findMilestone(projectId string, title string) (*milestone, error) {
+	resp, err := g.doApiRequestWithBody(
+		"GET",
+		url.Values{"title": {title}},
+		nil,
+		"projects",
+		url.QueryEscape(projectId),
+		"milestones",
+	)
+	if nil != err {
+		return nil, err
+	}
+
+	var milestones []milestone
+	err = g.decodeApiResponse(resp, 200, &milestones)
+	if nil != err {
+		return nil, err
+	}
+
+	for _, m := range milestones {
+		if m.Title == title {
+			return &m, nil
+		}
+	}
+
+	return nil, fmt.Errorf("Unknown milestone: %s\n", title)
+}
+
+/// Assignees, also from older servers only having a single assignee
+func (i issue) AllAssignees() []user {
+	if len(i.Assignees) == 0 && i.Assignee != nil {
+		return []user{*i.Assignee}
+	}
+	return i.Assignees
+}
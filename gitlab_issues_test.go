@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	. "github.com/smartystreets/goconvey/convey"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestUpdateIssue(t *testing.T) {
+	Convey("Given an issue", t, func() {
+		var options map[string]interface{}
+
+		sr, reqChan := serveAndCatchJson(t, &options)
+		u := urlMustParse(t, sr.URL)
+		g := newGitlab(u.Host)
+		g.token = "my-private-token"
+
+		Convey("When closing it and clearing its labels", func() {
+			labels := ""
+			g.updateIssue("group/project", 42, issueRequest{StateEvent: ISSUE_STATE_EVENT_CLOSE, Labels: &labels})
+
+			Convey("Only those fields should be sent", func() {
+				req := <-reqChan
+				So(req.Method, ShouldEqual, "PUT")
+				So(req.URL.String(), ShouldEqual, fmt.Sprintf(
+					"http://%s/api/v4/projects/group%%2Fproject/issues/42?private_token=my-private-token",
+					u.Host,
+				))
+				So(options, ShouldResemble, map[string]interface{}{
+					"state_event": "close",
+					"labels":      "",
+				})
+			})
+		})
+	})
+}
+
+func TestFindIssue(t *testing.T) {
+	Convey("Given a gitlab server with issues", t, func() {
+		var req *http.Request
+		issues := []issue{
+			issue{Id: 120, Iid: 7, Title: "Login fails"},
+			issue{Id: 121, Iid: 8, Title: "Logout fails"},
+		}
+
+		sr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			req = r
+			err := json.NewEncoder(w).Encode(issues)
+			if nil != err {
+				t.Fatal(err)
+			}
+		}))
+
+		u := urlMustParse(t, sr.URL)
+		g := newGitlab(u.Host)
+		g.token = "my-private-token"
+
+		Convey("When finding one by iid", func() {
+			found, err := g.findIssue("17", 8)
+
+			Convey("It should be queried in any state", func() {
+				So(err, ShouldBeNil)
+				So(found.Id, ShouldEqual, 121)
+				So(req.URL.Query().Get("state"), ShouldEqual, "all")
+				So(req.URL.Query().Get("iids[]"), ShouldEqual, "8")
+			})
+		})
+
+		Convey("When finding an unknown one", func() {
+			_, err := g.findIssue("17", 9)
+
+			Convey("It should fail", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
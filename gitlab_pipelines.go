@@ -0,0 +1,496 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type job struct {
+	Id            int        `json:"id"`
+	Name          string     `json:"name"`
+	Stage         string     `json:"stage"`
+	Status        string     `json:"status"`
+	Ref           string     `json:"ref"`
+	Tag           bool       `json:"tag"`
+	AllowFailure  bool       `json:"allow_failure"`
+	FailureReason string     `json:"failure_reason"`
+	WebUrl        string     `json:"web_url"`
+	User          *user      `json:"user"`
+	Pipeline      *pipeline  `json:"pipeline"`
+	CreatedAt     time.Time  `json:"created_at"`
+	StartedAt     *time.Time `json:"started_at"`
+	FinishedAt    *time.Time `json:"finished_at"`
+
+	Duration       float64 `json:"duration"`
+	QueuedDuration float64 `json:"queued_duration"`
+}
+
+/// Jobs of a stage, in the order of the pipeline
+type pipelineStage struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Jobs   []job  `json:"jobs"`
+}
+
+/// Pipeline with the jobs of each stage, as shown by ci status
+type pipelineDetails struct {
+	pipeline
+	Stages []pipelineStage `json:"stages"`
+}
+
+/// Pipelines of a project, newest first
+func (g gitlab) getPipelines(projectId string, query url.Values) ([]pipeline, error) {
+	resp, err := g.doApiRequestWithBody(
+		"GET",
+		query,
+		nil,
+		"projects",
+		url.QueryEscape(projectId),
+		"pipelines",
+	)
+	if nil != err {
+		return nil, err
+	}
+
+	var pipelines []pipeline
+	err = g.decodeApiResponse(resp, 200, &pipelines)
+	if nil != err {
+		return nil, err
+	}
+
+	return pipelines, nil
+}
+
+/// Pipelines of a project page by page, newest first
+func (g gitlab) eachPipelinePage(projectId string, query url.Values, each func([]pipeline) error) error {
+	return g.eachPage(query, func(resp *http.Response) error {
+		var page []pipeline
+		err := g.decodeApiResponse(resp, 200, &page)
+		if nil != err {
+			return err
+		}
+		return each(page)
+	}, "projects", url.QueryEscape(projectId), "pipelines")
+}
+
+func (g gitlab) getPipeline(projectId string, pipelineId int) (*pipeline, error) {
+	resp, err := g.doApiRequest(
+		"GET",
+		"projects",
+		url.QueryEscape(projectId),
+		"pipelines",
+		strconv.Itoa(pipelineId),
+	)
+	if nil != err {
+		return nil, err
+	}
+
+	var p pipeline
+	err = g.decodeApiResponse(resp, 200, &p)
+	if nil != err {
+		return nil, err
+	}
+
+	return &p, nil
+}
+
+/// Latest pipeline of a branch or tag
+func (g gitlab) getLatestPipeline(projectId, ref string) (*pipeline, error) {
+	pipelines, err := g.getPipelines(projectId, url.Values{
+		"ref":      {ref},
+		"per_page": {"1"},
+	})
+	if nil != err {
+		return nil, err
+	}
+	if len(pipelines) == 0 {
+		return nil, fmt.Errorf("No pipeline for: %s\n", ref)
+	}
+
+	return g.getPipeline(projectId, pipelines[0].Id)
+}
+
+/// Jobs of a pipeline, without those retried
+func (g gitlab) getPipelineJobs(projectId string, pipelineId int) ([]job, error) {
+	var jobs []job
+	err := g.eachPage(url.Values{}, func(resp *http.Response) error {
+		var page []job
+		err := g.decodeApiResponse(resp, 200, &page)
+		if nil != err {
+			return err
+		}
+		jobs = append(jobs, page...)
+		return nil
+	}, "projects", url.QueryEscape(projectId), "pipelines", strconv.Itoa(pipelineId), "jobs")
+
+	return jobs, err
+}
+
+/// Pipeline with its jobs grouped by stage
+func (g gitlab) getPipelineDetails(projectId string, pipelineId int) (*pipelineDetails, error) {
+	p, err := g.getPipeline(projectId, pipelineId)
+	if nil != err {
+		return nil, err
+	}
+
+	jobs, err := g.getPipelineJobs(projectId, pipelineId)
+	if nil != err {
+		return nil, err
+	}
+
+	return &pipelineDetails{pipeline: *p, Stages: pipelineStages(jobs)}, nil
+}
+
+/// Fill in the head pipeline of merge requests lacking one, by the latest pipelines of their projects
+func (g gitlab) loadPipelines(projectId string, requests []mergeRequest) error {
+	byProject := make(map[string][]int)
+	for i, request := range requests {
+		if request.HeadPipeline != nil || request.Pipeline != nil {
+			continue
+		}
+		project := projectId
+		if request.ProjectId != 0 {
+			project = strconv.Itoa(request.ProjectId)
+		}
+		byProject[project] = append(byProject[project], i)
+	}
+
+	for project, indexes := range byProject {
+		pipelines, err := g.getPipelines(project, url.Values{"per_page": {strconv.Itoa(PAGE_SIZE)}})
+		if nil != err {
+			return err
+		}
+		for _, i := range indexes {
+			requests[i].HeadPipeline = requests[i].latestPipeline(pipelines)
+		}
+	}
+
+	return nil
+}
+
+/// Newest of the pipelines for the head of the merge request, or else for its source branch
+func (r mergeRequest) latestPipeline(pipelines []pipeline) *pipeline {
+	mergeRequestRef := "refs/merge-requests/" + strconv.Itoa(r.Iid) + "/head"
+	var latest *pipeline
+	for i, p := range pipelines {
+		if p.Ref != r.SourceBranch && p.Ref != mergeRequestRef {
+			continue
+		}
+		if r.Sha != "" && p.Sha == r.Sha {
+			return &pipelines[i]
+		}
+		if nil == latest {
+			latest = &pipelines[i]
+		}
+	}
+	return latest
+}
+
+/// Group jobs by stage in the order they run, jobs are listed newest first by the api
+func pipelineStages(jobs []job) []pipelineStage {
+	ordered := append([]job{}, jobs...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].Id < ordered[j].Id
+	})
+
+	var stages []pipelineStage
+	index := make(map[string]int)
+	for _, j := range ordered {
+		i, ok := index[j.Stage]
+		if !ok {
+			i = len(stages)
+			index[j.Stage] = i
+			stages = append(stages, pipelineStage{Name: j.Stage})
+		}
+		stages[i].Jobs = append(stages[i].Jobs, j)
+	}
+
+	for i := range stages {
+		stages[i].Status = stageStatus(stages[i].Jobs)
+	}
+	return stages
+}
+
+/// Status of a stage from those of its jobs, failures allowed count as success
+func stageStatus(jobs []job) string {
+	counts := make(map[string]int)
+	for _, j := range jobs {
+		status := j.Status
+		if status == PIPELINE_STATUS_FAILED && j.AllowFailure {
+			status = "success"
+		}
+		counts[status]++
+	}
+
+	for _, status := range []string{"running", "pending", "preparing", "waiting_for_resource", PIPELINE_STATUS_FAILED, "canceled", "created", "scheduled", "manual", "success", "skipped"} {
+		if counts[status] > 0 {
+			return status
+		}
+	}
+	return ""
+}
+
+func (g gitlab) getJob(projectId string, jobId int) (*job, error) {
+	resp, err := g.doApiRequest(
+		"GET",
+		"projects",
+		url.QueryEscape(projectId),
+		"jobs",
+		strconv.Itoa(jobId),
+	)
+	if nil != err {
+		return nil, err
+	}
+
+	var j job
+	err = g.decodeApiResponse(resp, 200, &j)
+	if nil != err {
+		return nil, err
+	}
+
+	return &j, nil
+}
+
+/// Find a job of a pipeline by name
+func (g gitlab) findJob(projectId string, pipelineId int, name string) (*job, error) {
+	jobs, err := g.getPipelineJobs(projectId, pipelineId)
+	if nil != err {
+		return nil, err
+	}
+
+	for i := range jobs {
+		if jobs[i].Name == name {
+			return &jobs[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("No job %s in pipeline #%d\n", name, pipelineId)
+}
+
+/// Log of a job from offset on, servers ignoring the range send all of it
+func (g gitlab) getJobTrace(projectId string, jobId int, offset int64) ([]byte, error) {
+	req, err := g.newApiRequest(
+		"GET",
+		nil,
+		nil,
+		"projects",
+		url.QueryEscape(projectId),
+		"jobs",
+		strconv.Itoa(jobId),
+		"trace",
+	)
+	if nil != err {
+		return nil, err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", "bytes="+strconv.FormatInt(offset, 10)+"-")
+	}
+
+	client := http.Client{}
+	resp, err := client.Do(req)
+	if nil != err {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusRequestedRangeNotSatisfiable:
+		// Nothing new
+		return nil, nil
+	case http.StatusPartialContent:
+		return ioutil.ReadAll(resp.Body)
+	case http.StatusOK:
+		trace, err := ioutil.ReadAll(resp.Body)
+		if nil != err || int64(len(trace)) <= offset {
+			return nil, err
+		}
+		return trace[offset:], nil
+	}
+
+	return nil, g.decodeApiResponse(resp, 200, nil)
+}
+
+/// Variable of a pipeline to run, or of a manual job to play
+type pipelineVariable struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+type pipelineCreateRequest struct {
+	Ref       string             `json:"ref"`
+	Variables []pipelineVariable `json:"variables,omitempty"`
+}
+
+type jobPlayRequest struct {
+	JobVariablesAttributes []pipelineVariable `json:"job_variables_attributes,omitempty"`
+}
+
+/// Run a new pipeline for a branch or tag
+func (g gitlab) createPipeline(projectId, ref string, variables []pipelineVariable) (*pipeline, error) {
+	resp, err := g.doApiRequestWithBody(
+		"POST",
+		nil,
+		pipelineCreateRequest{Ref: ref, Variables: variables},
+		"projects",
+		url.QueryEscape(projectId),
+		"pipeline",
+	)
+	if nil != err {
+		return nil, err
+	}
+
+	var created pipeline
+	err = g.decodeApiResponse(resp, 201, &created)
+	if nil != err {
+		return nil, err
+	}
+
+	return &created, nil
+}
+
+/// Retry the failed and canceled jobs of a pipeline
+func (g gitlab) retryPipeline(projectId string, pipelineId int) (*pipeline, error) {
+	return g.postPipelineAction(projectId, pipelineId, "retry", 201)
+}
+
+/// Cancel the running and pending jobs of a pipeline
+func (g gitlab) cancelPipeline(projectId string, pipelineId int) (*pipeline, error) {
+	return g.postPipelineAction(projectId, pipelineId, "cancel", 200)
+}
+
+func (g gitlab) postPipelineAction(projectId string, pipelineId int, action string, expectedStatusCode int) (*pipeline, error) {
+	resp, err := g.doApiRequest(
+		"POST",
+		"projects",
+		url.QueryEscape(projectId),
+		"pipelines",
+		strconv.Itoa(pipelineId),
+		action,
+	)
+	if nil != err {
+		return nil, err
+	}
+
+	var p pipeline
+	err = g.decodeApiResponse(resp, expectedStatusCode, &p)
+	if nil != err {
+		return nil, err
+	}
+
+	return &p, nil
+}
+
+/// Retry a job, returns the new job
+func (g gitlab) retryJob(projectId string, jobId int) (*job, error) {
+	return g.postJobAction(projectId, jobId, "retry", nil, 201)
+}
+
+func (g gitlab) cancelJob(projectId string, jobId int) (*job, error) {
+	return g.postJobAction(projectId, jobId, "cancel", nil, 201)
+}
+
+/// Start a manual job
+func (g gitlab) playJob(projectId string, jobId int, variables []pipelineVariable) (*job, error) {
+	var body interface{}
+	if len(variables) > 0 {
+		body = jobPlayRequest{JobVariablesAttributes: variables}
+	}
+	return g.postJobAction(projectId, jobId, "play", body, 200)
+}
+
+func (g gitlab) postJobAction(projectId string, jobId int, action string, body interface{}, expectedStatusCode int) (*job, error) {
+	resp, err := g.doApiRequestWithBody(
+		"POST",
+		nil,
+		body,
+		"projects",
+		url.QueryEscape(projectId),
+		"jobs",
+		strconv.Itoa(jobId),
+		action,
+	)
+	if nil != err {
+		return nil, err
+	}
+
+	var j job
+	err = g.decodeApiResponse(resp, expectedStatusCode, &j)
+	if nil != err {
+		return nil, err
+	}
+
+	return &j, nil
+}
+
+/// Latest successful job of a name for a branch or tag, searching its newest pipelines
+func (g gitlab) findLatestSuccessfulJob(projectId, ref, name string) (*job, error) {
+	var found *job
+	err := g.eachPipelinePage(projectId, url.Values{"ref": {ref}}, func(page []pipeline) error {
+		for _, p := range page {
+			jobs, err := g.getPipelineJobs(projectId, p.Id)
+			if nil != err {
+				return err
+			}
+			for i := range jobs {
+				if jobs[i].Name == name && jobs[i].Status == "success" {
+					found = &jobs[i]
+					return ErrStopPaging
+				}
+			}
+		}
+		// Only search the newest page of pipelines
+		return ErrStopPaging
+	})
+	if nil != err {
+		return nil, err
+	}
+	if nil == found {
+		return nil, fmt.Errorf("No successful job %s for: %s\n", name, ref)
+	}
+
+	return found, nil
+}
+
+/// Download artifacts of a job, the archive or a single file at path, from offset on if it still matches validator.
+/// Returns the response for 200, 206 and 416, which has the size of the download in its Content-Range header
+func (g gitlab) downloadJobArtifacts(projectId string, jobId int, path string, offset int64, validator string) (*http.Response, error) {
+	segments := []string{"projects", url.QueryEscape(projectId), "jobs", strconv.Itoa(jobId), "artifacts"}
+	req, err := g.newApiRequest("GET", nil, nil, append(segments, artifactPathSegments(path)...)...)
+	if nil != err {
+		return nil, err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", "bytes="+strconv.FormatInt(offset, 10)+"-")
+		req.Header.Set("If-Range", validator)
+	}
+
+	client := http.Client{}
+	resp, err := client.Do(req)
+	if nil != err {
+		return nil, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusPartialContent, http.StatusRequestedRangeNotSatisfiable:
+		return resp, nil
+	}
+
+	return nil, g.decodeApiResponse(resp, 200, nil)
+}
+
+/// Escaped segments of a path in the artifacts archive
+func artifactPathSegments(path string) []string {
+	var segments []string
+	for _, segment := range strings.Split(path, "/") {
+		if segment != "" {
+			segments = append(segments, url.PathEscape(segment))
+		}
+	}
+	return segments
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	. "github.com/smartystreets/goconvey/convey"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGetPipelines(t *testing.T) {
+	Convey("Given a gitlab server with pipelines", t, func() {
+		var req *http.Request
+		pipelines := []pipeline{
+			pipeline{Id: 42, Ref: "master", Status: "running"},
+			pipeline{Id: 41, Ref: "feature", Status: "failed"},
+		}
+
+		sr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			req = r
+			err := json.NewEncoder(w).Encode(pipelines)
+			if nil != err {
+				t.Fatal(err)
+			}
+		}))
+
+		u := urlMustParse(t, sr.URL)
+		g := newGitlab(u.Host)
+		g.token = "my-private-token"
+
+		Convey("When getting the pipelines", func() {
+			gotten, err := g.getPipelines("group/project", url.Values{"per_page": {"20"}})
+
+			Convey("They should be decoded", func() {
+				So(err, ShouldBeNil)
+				So(req.URL.String(), ShouldEqual, fmt.Sprintf(
+					"http://%s/api/v4/projects/group%%2Fproject/pipelines?private_token=my-private-token&per_page=20",
+					u.Host,
+				))
+				So(gotten, ShouldHaveLength, 2)
+				So(gotten[0].Id, ShouldEqual, 42)
+				So(gotten[1].Status, ShouldEqual, "failed")
+			})
+		})
+	})
+}
+
+func TestGetJobTrace(t *testing.T) {
+	Convey("Given a gitlab server with the log of a job", t, func() {
+		var req *http.Request
+		trace := "Running with gitlab-runner\n$ make\n"
+
+		sr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			req = r
+			http.ServeContent(w, r, "trace", time.Time{}, strings.NewReader(trace))
+		}))
+
+		u := urlMustParse(t, sr.URL)
+		g := newGitlab(u.Host)
+		g.token = "my-private-token"
+
+		Convey("When getting the log from the start", func() {
+			gotten, err := g.getJobTrace("group/project", 7, 0)
+
+			Convey("The whole log should be returned", func() {
+				So(err, ShouldBeNil)
+				So(req.URL.EscapedPath(), ShouldEqual, "/api/v4/projects/group%2Fproject/jobs/7/trace")
+				So(req.Header.Get("Range"), ShouldEqual, "")
+				So(string(gotten), ShouldEqual, trace)
+			})
+		})
+
+		Convey("When getting the log from an offset", func() {
+			gotten, err := g.getJobTrace("group/project", 7, 27)
+
+			Convey("Only the rest should be returned", func() {
+				So(err, ShouldBeNil)
+				So(req.Header.Get("Range"), ShouldEqual, "bytes=27-")
+				So(string(gotten), ShouldEqual, "$ make\n")
+			})
+		})
+
+		Convey("When getting the log from its end", func() {
+			gotten, err := g.getJobTrace("group/project", 7, int64(len(trace)))
+
+			Convey("Nothing should be returned", func() {
+				So(err, ShouldBeNil)
+				So(gotten, ShouldBeEmpty)
+			})
+		})
+	})
+}
+
+func TestCreatePipeline(t *testing.T) {
+	Convey("Given a gitlab server", t, func() {
+		var req *http.Request
+		var body pipelineCreateRequest
+
+		sr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			req = r
+			err := json.NewDecoder(r.Body).Decode(&body)
+			if nil != err {
+				t.Fatal(err)
+			}
+			w.WriteHeader(201)
+			fmt.Fprintf(w, `{"id": 43, "ref": %q, "status": "created"}`, body.Ref)
+		}))
+
+		u := urlMustParse(t, sr.URL)
+		g := newGitlab(u.Host)
+		g.token = "my-private-token"
+
+		Convey("When running a pipeline with variables", func() {
+			created, err := g.createPipeline("group/project", "master", []pipelineVariable{{"DEPLOY", "staging"}})
+
+			Convey("The ref and variables should be sent", func() {
+				So(err, ShouldBeNil)
+				So(req.Method, ShouldEqual, "POST")
+				So(req.URL.EscapedPath(), ShouldEqual, "/api/v4/projects/group%2Fproject/pipeline")
+				So(body.Ref, ShouldEqual, "master")
+				So(body.Variables, ShouldResemble, []pipelineVariable{{"DEPLOY", "staging"}})
+				So(created.Id, ShouldEqual, 43)
+			})
+		})
+	})
+}
+
+func TestDownloadArtifacts(t *testing.T) {
+	Convey("Given a gitlab server with artifacts", t, func() {
+		var req *http.Request
+		artifacts := "PK artifacts"
+
+		sr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			req = r
+			w.Header().Set("ETag", `"v1"`)
+			http.ServeContent(w, r, "artifacts.zip", time.Time{}, strings.NewReader(artifacts))
+		}))
+
+		u := urlMustParse(t, sr.URL)
+		g := newGitlab(u.Host)
+		g.token = "my-private-token"
+
+		Convey("When downloading a file of the artifacts of a job", func() {
+			resp, err := g.downloadJobArtifacts("group/project", 7, "build/release notes.txt", 0, "")
+
+			Convey("The path of the file should be escaped", func() {
+				So(err, ShouldBeNil)
+				resp.Body.Close()
+				So(req.URL.EscapedPath(), ShouldEqual, "/api/v4/projects/group%2Fproject/jobs/7/artifacts/build/release%20notes.txt")
+				So(req.Header.Get("Range"), ShouldEqual, "")
+				So(resp.StatusCode, ShouldEqual, 200)
+			})
+		})
+
+		Convey("When continuing a download of unchanged artifacts", func() {
+			resp, err := g.downloadJobArtifacts("group/project", 7, "", 3, `"v1"`)
+
+			Convey("The rest of the archive should be requested if still the same", func() {
+				So(err, ShouldBeNil)
+				resp.Body.Close()
+				So(req.URL.EscapedPath(), ShouldEqual, "/api/v4/projects/group%2Fproject/jobs/7/artifacts")
+				So(req.Header.Get("Range"), ShouldEqual, "bytes=3-")
+				So(req.Header.Get("If-Range"), ShouldEqual, `"v1"`)
+				So(resp.StatusCode, ShouldEqual, 206)
+			})
+		})
+
+		Convey("When continuing a download of changed artifacts", func() {
+			resp, err := g.downloadJobArtifacts("group/project", 7, "", 3, `"v0"`)
+
+			Convey("The whole archive should be sent", func() {
+				So(err, ShouldBeNil)
+				resp.Body.Close()
+				So(resp.StatusCode, ShouldEqual, 200)
+			})
+		})
+
+		Convey("When continuing from the end", func() {
+			resp, err := g.downloadJobArtifacts("group/project", 7, "", int64(len(artifacts)), `"v1"`)
+
+			Convey("The size of the artifacts should be told", func() {
+				So(err, ShouldBeNil)
+				resp.Body.Close()
+				So(resp.StatusCode, ShouldEqual, 416)
+				So(downloadSize(resp), ShouldEqual, len(artifacts))
+			})
+		})
+	})
+}
+
+func TestPipelineStages(t *testing.T) {
+	Convey("Given jobs of a pipeline, newest first", t, func() {
+		jobs := []job{
+			job{Id: 5, Stage: "deploy", Status: "manual"},
+			job{Id: 4, Stage: "test", Status: "running"},
+			job{Id: 3, Stage: "test", Status: "failed", AllowFailure: true},
+			job{Id: 2, Stage: "build", Status: "success"},
+			job{Id: 1, Stage: "build", Status: "failed", AllowFailure: true},
+		}
+
+		Convey("They should be grouped by stage in the order they run", func() {
+			stages := pipelineStages(jobs)
+			So(stages, ShouldHaveLength, 3)
+			So(stages[0].Name, ShouldEqual, "build")
+			So(stages[0].Status, ShouldEqual, "success")
+			So(stages[0].Jobs[0].Id, ShouldEqual, 1)
+			So(stages[1].Status, ShouldEqual, "running")
+			So(stages[2].Status, ShouldEqual, "manual")
+		})
+	})
+}
+
+func TestLatestPipeline(t *testing.T) {
+	Convey("Given pipelines of a project, newest first", t, func() {
+		pipelines := []pipeline{
+			pipeline{Id: 44, Ref: "master", Sha: "aaa"},
+			pipeline{Id: 43, Ref: "feature", Sha: "ccc"},
+			pipeline{Id: 42, Ref: "refs/merge-requests/7/head", Sha: "bbb"},
+		}
+
+		Convey("The pipeline of the head should be found", func() {
+			So(mergeRequest{Iid: 7, SourceBranch: "feature", Sha: "bbb"}.latestPipeline(pipelines).Id, ShouldEqual, 42)
+		})
+
+		Convey("The newest of the source branch should be found otherwise", func() {
+			So(mergeRequest{Iid: 7, SourceBranch: "feature", Sha: "ddd"}.latestPipeline(pipelines).Id, ShouldEqual, 43)
+			So(mergeRequest{Iid: 8, SourceBranch: "other"}.latestPipeline(pipelines), ShouldBeNil)
+		})
+	})
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+type mergeRequestVersion struct {
+	Id             int                `json:"id"`
+	HeadCommitSha  string             `json:"head_commit_sha"`
+	BaseCommitSha  string             `json:"base_commit_sha"`
+	StartCommitSha string             `json:"start_commit_sha"`
+	CreatedAt      time.Time          `json:"created_at"`
+	State          string             `json:"state"`
+	RealSize       string             `json:"real_size"`
+	Diffs          []mergeRequestDiff `json:"diffs,omitempty"`
+}
+
+type mergeRequestDiff struct {
+	OldPath     string `json:"old_path"`
+	NewPath     string `json:"new_path"`
+	AMode       string `json:"a_mode"`
+	BMode       string `json:"b_mode"`
+	Diff        string `json:"diff"`
+	NewFile     bool   `json:"new_file"`
+	RenamedFile bool   `json:"renamed_file"`
+	DeletedFile bool   `json:"deleted_file"`
+}
+
+type draftNote struct {
+	Id       int           `json:"id"`
+	AuthorId int           `json:"author_id"`
+	Note     string        `json:"note"`
+	Position *notePosition `json:"position"`
+}
+
+type draftNoteCreateRequest struct {
+	Note     string        `json:"note"`
+	Position *notePosition `json:"position,omitempty"`
+}
+
+/// Diff position of a line in this version, for commenting on it
+func (v mergeRequestVersion) position(diff mergeRequestDiff, line diffLine) notePosition {
+	position := notePosition{
+		BaseSha:      v.BaseCommitSha,
+		StartSha:     v.StartCommitSha,
+		HeadSha:      v.HeadCommitSha,
+		PositionType: "text",
+		OldPath:      diff.OldPath,
+		NewPath:      diff.NewPath,
+	}
+	if line.Kind != "+" {
+		position.OldLine = line.OldLine
+	}
+	if line.Kind != "-" {
+		position.NewLine = line.NewLine
+	}
+
+	return position
+}
+
+/// Path to show for the diff, eg. "old.go -> new.go" for renames
+func (d mergeRequestDiff) Path() string {
+	if d.RenamedFile {
+		return d.OldPath + " -> " + d.NewPath
+	}
+	return d.NewPath
+}
+
+/// Versions of a merge request, newest first
+func (g gitlab) getMergeRequestVersions(projectId string, mergeRequestIid int) ([]mergeRequestVersion, error) {
+	resp, err := g.doApiRequest(
+		"GET",
+		"projects",
+		url.QueryEscape(projectId),
+		"merge_requests",
+		strconv.Itoa(mergeRequestIid),
+		"versions",
+	)
+	if nil != err {
+		return nil, err
+	}
+
+	var versions []mergeRequestVersion
+	err = g.decodeApiResponse(resp, 200, &versions)
+	if nil != err {
+		return nil, err
+	}
+
+	return versions, nil
+}
+
+/// Get version with diffs, the latest if versionId is 0
+func (g gitlab) getMergeRequestVersion(projectId string, mergeRequestIid int, versionId int) (*mergeRequestVersion, error) {
+	if versionId == 0 {
+		versions, err := g.getMergeRequestVersions(projectId, mergeRequestIid)
+		if nil != err {
+			return nil, err
+		}
+		if len(versions) == 0 {
+			return nil, fmt.Errorf("Merge request !%d has no versions\n", mergeRequestIid)
+		}
+		versionId = versions[0].Id
+	}
+
+	resp, err := g.doApiRequest(
+		"GET",
+		"projects",
+		url.QueryEscape(projectId),
+		"merge_requests",
+		strconv.Itoa(mergeRequestIid),
+		"versions",
+		strconv.Itoa(versionId),
+	)
+	if nil != err {
+		return nil, err
+	}
+
+	var version mergeRequestVersion
+	err = g.decodeApiResponse(resp, 200, &version)
+	if nil != err {
+		return nil, err
+	}
+
+	return &version, nil
+}
+
+/// All draft notes of a merge request, over as many pages as there are
+func (g gitlab) getDraftNotes(projectId string, mergeRequestIid int) ([]draftNote, error) {
+	var drafts []draftNote
+	err := g.eachPage(nil, func(resp *http.Response) error {
+		var page []draftNote
+		err := g.decodeApiResponse(resp, 200, &page)
+		if nil != err {
+			return err
+		}
+		drafts = append(drafts, page...)
+		return nil
+	}, "projects", url.QueryEscape(projectId), "merge_requests", strconv.Itoa(mergeRequestIid), "draft_notes")
+	if nil != err {
+		return nil, err
+	}
+
+	return drafts, nil
+}
+
+func (g gitlab) createDraftNote(projectId string, mergeRequestIid int, draft draftNoteCreateRequest) (*draftNote, error) {
+	resp, err := g.doApiRequestWithBody(
+		"POST",
+		nil,
+		draft,
+		"projects",
+		url.QueryEscape(projectId),
+		"merge_requests",
+		strconv.Itoa(mergeRequestIid),
+		"draft_notes",
+	)
+	if nil != err {
+		return nil, err
+	}
+
+	var created draftNote
+	err = g.decodeApiResponse(resp, 201, &created)
+	if nil != err {
+		return nil, err
+	}
+
+	return &created, nil
+}
+
+func (g gitlab) deleteDraftNote(projectId string, mergeRequestIid int, draftNoteId int) error {
+	resp, err := g.doApiRequest(
+		"DELETE",
+		"projects",
+		url.QueryEscape(projectId),
+		"merge_requests",
+		strconv.Itoa(mergeRequestIid),
+		"draft_notes",
+		strconv.Itoa(draftNoteId),
+	)
+	if nil != err {
+		return err
+	}
+
+	return g.decodeApiResponse(resp, 204, nil)
+}
+
+/// Publish all pending draft notes at once
+func (g gitlab) publishDraftNotes(projectId string, mergeRequestIid int) error {
+	resp, err := g.doApiRequest(
+		"POST",
+		"projects",
+		url.QueryEscape(projectId),
+		"merge_requests",
+		strconv.Itoa(mergeRequestIid),
+		"draft_notes",
+		"bulk_publish",
+	)
+	if nil != err {
+		return err
+	}
+
+	return g.decodeApiResponse(resp, 204, nil)
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	. "github.com/smartystreets/goconvey/convey"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCreateDraftNote(t *testing.T) {
+	Convey("Given a line in a merge request version", t, func() {
+		var draft draftNoteCreateRequest
+
+		sr, reqChan := serveAndCatchJson(t, &draft)
+		u := urlMustParse(t, sr.URL)
+		g := newGitlab(u.Host)
+		g.token = "my-private-token"
+
+		version := mergeRequestVersion{
+			BaseCommitSha:  "base",
+			StartCommitSha: "start",
+			HeadCommitSha:  "head",
+		}
+		diff := mergeRequestDiff{OldPath: "main.go", NewPath: "main.go"}
+
+		Convey("When commenting on a context line", func() {
+			position := version.position(diff, diffLine{Kind: " ", OldLine: 3, NewLine: 4})
+			g.createDraftNote("17", 13, draftNoteCreateRequest{Note: "Hmm", Position: &position})
+
+			Convey("The draft should be posted with both line numbers", func() {
+				req := <-reqChan
+				So(req.Method, ShouldEqual, "POST")
+				So(req.URL.String(), ShouldEqual, fmt.Sprintf(
+					"http://%s/api/v4/projects/17/merge_requests/13/draft_notes?private_token=my-private-token",
+					u.Host,
+				))
+				So(draft.Note, ShouldEqual, "Hmm")
+				So(*draft.Position, ShouldResemble, notePosition{
+					BaseSha:      "base",
+					StartSha:     "start",
+					HeadSha:      "head",
+					PositionType: "text",
+					OldPath:      "main.go",
+					NewPath:      "main.go",
+					OldLine:      3,
+					NewLine:      4,
+				})
+			})
+		})
+
+		Convey("When commenting on an added line", func() {
+			position := version.position(diff, diffLine{Kind: "+", NewLine: 4})
+
+			Convey("Only the new line should be set", func() {
+				So(position.OldLine, ShouldEqual, 0)
+				So(position.NewLine, ShouldEqual, 4)
+			})
+		})
+	})
+}
+
+func TestGetDraftNotes(t *testing.T) {
+	Convey("Given a gitlab server with two pages of draft notes", t, func() {
+		sr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			drafts := []draftNote{draftNote{Id: 1}, draftNote{Id: 2}}
+			if r.URL.Query().Get("page") == "2" {
+				drafts = []draftNote{draftNote{Id: 3}}
+			} else {
+				w.Header().Set("X-Next-Page", "2")
+			}
+			json.NewEncoder(w).Encode(drafts)
+		}))
+		defer sr.Close()
+
+		g := newGitlab(urlMustParse(t, sr.URL).Host)
+
+		Convey("All drafts should be gotten", func() {
+			drafts, err := g.getDraftNotes("17", 13)
+			So(err, ShouldBeNil)
+			So(drafts, ShouldHaveLength, 3)
+			So(drafts[2].Id, ShouldEqual, 3)
+		})
+	})
+}
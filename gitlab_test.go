@@ -44,7 +44,7 @@ func TestGetSession(t *testing.T) {
 			g.getSession("user", "password")
 
 			Convey("The client should post the correct json to the api", func() {
-				expectedUrl := fmt.Sprintf("http://%s/api/v3/session", u.Host)
+				expectedUrl := fmt.Sprintf("http://%s/api/v4/session", u.Host)
 				req := <-reqChan
 				So(req.Method, ShouldEqual, "POST")
 				So(req.URL.String(), ShouldEqual, expectedUrl)
@@ -76,7 +76,11 @@ func TestCreateMergeRequest(t *testing.T) {
 		g.token = "my-private-token"
 
 		Convey("When creating a merge request", func() {
-			g.createMergeRequest("17", "source-branch", "target-branch", "my title")
+			g.createMergeRequest("17", mergeRequestCreateRequest{
+				SourceBranch: "source-branch",
+				TargetBranch: "target-branch",
+				Title:        "my title",
+			})
 
 			Convey("The request should match", func() {
 				req := <-reqChan
@@ -84,7 +88,7 @@ func TestCreateMergeRequest(t *testing.T) {
 				So(
 					req.URL.String(),
 					ShouldEqual,
-					fmt.Sprintf("http://%s/api/v3/projects/17/merge_requests?private_token=my-private-token", u.Host),
+					fmt.Sprintf("http://%s/api/v4/projects/17/merge_requests?private_token=my-private-token", u.Host),
 				)
 				So(
 					mr,
@@ -129,7 +133,7 @@ func TestQueryMergeRequests(t *testing.T) {
 		g.token = "my-private-token"
 
 		Convey("When creating a merge request", func() {
-			gottenMrs, err := g.queryMergeRequests("17", "shuffled")
+			gottenMrs, err := g.queryMergeRequests("17", url.Values{"state": {"shuffled"}})
 
 			Convey("The request should match", func() {
 				So(err, ShouldBeNil)
@@ -139,7 +143,7 @@ func TestQueryMergeRequests(t *testing.T) {
 					req.URL.String(),
 					ShouldEqual,
 					fmt.Sprintf(
-						"http://%s/api/v3/projects/17/merge_requests?private_token=my-private-token&state=shuffled",
+						"http://%s/api/v4/projects/17/merge_requests?private_token=my-private-token&per_page=100&state=shuffled",
 						u.Host,
 					),
 				)
@@ -148,6 +152,46 @@ func TestQueryMergeRequests(t *testing.T) {
 	})
 }
 
+func TestFindMergeRequest(t *testing.T) {
+	Convey("Given a gitlab server with a merge request", t, func() {
+		var req *http.Request
+		mrs := []mergeRequest{
+			mergeRequest{Id: 13, Iid: 17, Title: "my-title"},
+		}
+
+		sr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			req = r
+			err := json.NewEncoder(w).Encode(mrs)
+			if nil != err {
+				t.Fatal(err)
+			}
+		}))
+
+		u := urlMustParse(t, sr.URL)
+		g := newGitlab(u.Host)
+		g.token = "my-private-token"
+
+		Convey("When finding it by its iid", func() {
+			found, err := g.findMergeRequest("group/project", "all", 17)
+
+			Convey("Only that merge request should be queried", func() {
+				So(err, ShouldBeNil)
+				So(found.Id, ShouldEqual, 13)
+				So(req.URL.Query().Get("state"), ShouldEqual, "all")
+				So(req.URL.Query().Get("iids[]"), ShouldEqual, "17")
+			})
+		})
+
+		Convey("When finding another iid", func() {
+			_, err := g.findMergeRequest("group/project", "all", 18)
+
+			Convey("It should not be found", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
+
 func TestQueryMergeRequestsErrorMessage(t *testing.T) {
 	Convey("Given a gitlab server", t, func() {
 		var req *http.Request
@@ -169,7 +213,7 @@ func TestQueryMergeRequestsErrorMessage(t *testing.T) {
 		g.token = "my-private-token"
 
 		Convey("When creating a merge request", func() {
-			_, err := g.queryMergeRequests("17", "shuffled")
+			_, err := g.queryMergeRequests("17", url.Values{"state": {"shuffled"}})
 
 			Convey("The request should match", func() {
 				So(err, ShouldNotBeNil)
@@ -179,7 +223,7 @@ func TestQueryMergeRequestsErrorMessage(t *testing.T) {
 					req.URL.String(),
 					ShouldEqual,
 					fmt.Sprintf(
-						"http://%s/api/v3/projects/17/merge_requests?private_token=my-private-token&state=shuffled",
+						"http://%s/api/v4/projects/17/merge_requests?private_token=my-private-token&per_page=100&state=shuffled",
 						u.Host,
 					),
 				)
@@ -187,3 +231,171 @@ func TestQueryMergeRequestsErrorMessage(t *testing.T) {
 		})
 	})
 }
+
+func TestAcceptMergeRequest(t *testing.T) {
+	Convey("Given a gitlab server", t, func() {
+		var accept mergeRequestAcceptRequest
+
+		sr, reqChan := serveAndCatchJson(t, &accept)
+		u := urlMustParse(t, sr.URL)
+		g := newGitlab(u.Host)
+		g.token = "my-private-token"
+
+		Convey("When accepting a merge request", func() {
+			g.acceptMergeRequest("17", 13, mergeRequestAcceptRequest{
+				ShouldRemoveSourceBranch: true,
+				Squash:                   true,
+				Sha:                      "abc123",
+			})
+
+			Convey("The options should be sent along", func() {
+				req := <-reqChan
+				So(req.Method, ShouldEqual, "PUT")
+				So(
+					req.URL.String(),
+					ShouldEqual,
+					fmt.Sprintf("http://%s/api/v4/projects/17/merge_requests/13/merge?private_token=my-private-token", u.Host),
+				)
+				So(
+					accept,
+					ShouldResemble,
+					mergeRequestAcceptRequest{
+						ShouldRemoveSourceBranch: true,
+						Squash:                   true,
+						Sha:                      "abc123",
+					},
+				)
+			})
+		})
+	})
+}
+
+func TestMergeBlockers(t *testing.T) {
+	Convey("Given a merge request", t, func() {
+		mr := mergeRequest{
+			HeadPipeline: &pipeline{Id: 4, Status: "success"},
+			MergeStatus:  "can_be_merged",
+		}
+
+		Convey("It should be mergeable when nothing is wrong", func() {
+			So(mr.mergeBlockers(), ShouldBeEmpty)
+			mr.Approvals = &mergeRequestApprovals{ApprovalsRequired: 2}
+			So(mr.mergeBlockers(), ShouldBeEmpty)
+		})
+
+		Convey("It should be blocked by a failed pipeline, conflicts and missing approvals", func() {
+			mr.HeadPipeline.Status = "failed"
+			mr.HasConflicts = true
+			mr.Approvals = &mergeRequestApprovals{ApprovalsRequired: 2, ApprovalsLeft: 1}
+			blockers := mr.mergeBlockers()
+			So(blockers, ShouldResemble, []string{
+				"Pipeline #4 failed",
+				"Merge request has conflicts",
+				"Missing 1 of 2 required approvals",
+			})
+		})
+	})
+}
+
+func TestEligibleApprovers(t *testing.T) {
+	Convey("Given approvers and suggested approvers", t, func() {
+		approvals := mergeRequestApprovals{
+			Approvers: []approver{
+				approver{User: user{Id: 1, Username: "alice"}},
+			},
+			SuggestedApprovers: []user{
+				user{Id: 2, Username: "bob"},
+				user{Id: 1, Username: "alice"},
+			},
+		}
+
+		Convey("Each user should be eligible once", func() {
+			So(approvals.EligibleApprovers(), ShouldResemble, []user{
+				user{Id: 1, Username: "alice"},
+				user{Id: 2, Username: "bob"},
+			})
+		})
+	})
+}
+
+func TestQueryGroupMergeRequests(t *testing.T) {
+	Convey("Given a gitlab server", t, func() {
+		var req *http.Request
+		mrs := []mergeRequest{
+			mergeRequest{
+				Iid:        17,
+				References: &references{Full: "my-group/my-project!17"},
+			},
+		}
+
+		sr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			req = r
+			err := json.NewEncoder(w).Encode(mrs)
+			if nil != err {
+				t.Fatal(err)
+			}
+		}))
+
+		u := urlMustParse(t, sr.URL)
+		g := newGitlab(u.Host)
+		g.token = "my-private-token"
+
+		Convey("When querying the merge requests of a group", func() {
+			gottenMrs, err := g.queryGlobalMergeRequests("my-group", url.Values{"reviewer_id": {"3"}})
+
+			Convey("The group endpoint should be used and the project path set", func() {
+				So(err, ShouldBeNil)
+				So(req.URL.String(), ShouldEqual, fmt.Sprintf(
+					"http://%s/api/v4/groups/my-group/merge_requests?private_token=my-private-token&per_page=100&reviewer_id=3&state=opened",
+					u.Host,
+				))
+				So(gottenMrs, ShouldHaveLength, 1)
+				So(gottenMrs[0].ProjectPath, ShouldEqual, "my-group/my-project")
+			})
+		})
+	})
+}
+
+func TestProjectPathFromPayload(t *testing.T) {
+	Convey("Given merge requests without references", t, func() {
+		Convey("The project path should be taken from the web url", func() {
+			mr := mergeRequest{WebUrl: "https://gitlab.example.com/group/sub/project/-/merge_requests/3"}
+			So(mr.projectPathFromPayload(), ShouldEqual, "group/sub/project")
+
+			mr = mergeRequest{WebUrl: "http://gitlab.example.com/group/project/merge_requests/3"}
+			So(mr.projectPathFromPayload(), ShouldEqual, "group/project")
+		})
+	})
+}
+
+func TestDecodeMergeRequestPayload(t *testing.T) {
+	Convey("Given a merge request payload", t, func() {
+		payload := `{
+			"iid": 1,
+			"state": "merged",
+			"labels": ["bug", "ui"],
+			"author": {"id": 1, "username": "alice", "name": "Alice"},
+			"assignee": {"id": 2, "username": "bob", "name": "Bob"},
+			"milestone": {"id": 3, "title": "v1.0"},
+			"merged_at": "2015-12-09T10:00:00Z",
+			"closed_at": null,
+			"pipeline": {"id": 4, "status": "success"},
+			"work_in_progress": true
+		}`
+
+		Convey("The nested types should be decoded", func() {
+			var mr mergeRequest
+			err := json.Unmarshal([]byte(payload), &mr)
+			So(err, ShouldBeNil)
+			So(mr.Author.Username, ShouldEqual, "alice")
+			So(mr.AllAssignees(), ShouldResemble, []user{user{Id: 2, Username: "bob", Name: "Bob"}})
+			So(mr.Milestone.Title, ShouldEqual, "v1.0")
+			So(mr.Labels, ShouldResemble, []string{"bug", "ui"})
+			So(mr.PipelineStatus(), ShouldEqual, "success")
+			So(mr.IsDraft(), ShouldBeTrue)
+			So(shortDate(mr.MergedAt), ShouldEqual, "2015-12-09 10:00")
+			So(shortDate(mr.ClosedAt), ShouldEqual, "")
+			So(usernames(mr.AllAssignees()), ShouldEqual, "@bob")
+		})
+	})
+}
@@ -0,0 +1,472 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/codegangsta/cli"
+	"log"
+	"strconv"
+	"strings"
+)
+
+/// Issue with its latest notes, as shown by issue view
+type issueDetails struct {
+	issue
+	Activity []note `json:"activity"`
+}
+
+// Create action for a particular issue, by ID or from the current branch, eg. "42-fix-login"
+func createActionForIssue(callback func(*cli.Context, gitlab, string, issue) error) func(*cli.Context) {
+	return func(c *cli.Context) {
+		server := needGitlab(c)
+		remoteUrl := needRemoteUrl(c)
+		server.token = needToken(c)
+
+		issueId, err := issueIdFromContext(c)
+		if nil != err {
+			log.Fatal(err)
+		}
+
+		found, err := server.findIssue(remoteUrl.path, issueId)
+		if nil != err {
+			log.Fatal(err)
+		}
+
+		err = callback(c, server, remoteUrl.path, *found)
+		if nil != err {
+			fatalPaging(err)
+		}
+	}
+}
+
+/// Issue ID from the first argument, or the number the current branch starts with
+func issueIdFromContext(c *cli.Context) (int, error) {
+	if arg := c.Args().First(); arg != "" {
+		issueId, err := strconv.Atoi(strings.TrimPrefix(arg, "#"))
+		if nil != err {
+			return 0, fmt.Errorf("You did not provide a valid ID")
+		}
+		return issueId, nil
+	}
+
+	currentBranch, err := needGitDir(c).getCurrentBranch()
+	if nil != err {
+		return 0, err
+	}
+	if issueId, ok := issueIdFromBranch(currentBranch); ok {
+		return issueId, nil
+	}
+
+	return 0, fmt.Errorf("No issue ID given, and branch %s does not start with one\n", currentBranch)
+}
+
+/// Number a branch starts with, like GitLab names branches for issues, eg. 42 for "42-fix-login"
+func issueIdFromBranch(branch string) (int, bool) {
+	branch = branch[strings.LastIndex(branch, "/")+1:]
+	digits := strings.IndexFunc(branch, func(r rune) bool {
+		return r < '0' || r > '9'
+	})
+	if digits == 0 || digits > 0 && branch[digits] != '-' {
+		return 0, false
+	}
+	if digits < 0 {
+		digits = len(branch)
+	}
+
+	issueId, err := strconv.Atoi(branch[:digits])
+	return issueId, nil == err && issueId > 0
+}
+
+/// List issues of the project as filtered by flags, up to --limit
+func listIssues(c *cli.Context) {
+	if formatHelp(c, IssueListTemplate, issue{}) {
+		return
+	}
+
+	r, err := newRenderer(c, "default-issue", IssueListTemplate, issueColumns, true)
+	if nil != err {
+		log.Fatal(err)
+	}
+	r.withTable(c, issueTableColumns, issueTableDefaultColumns)
+
+	server := needGitlab(c)
+	server.token = needToken(c)
+	projectId := needRemoteUrl(c).path
+
+	query, err := queryFromFlags(c, server)
+	if nil != err {
+		log.Fatal(err)
+	}
+
+	stopPager := startPager(c)
+	limit := newPageLimit(c, query)
+	err = server.eachIssuePage(projectId, query, func(page []issue) error {
+		for _, i := range page[:limit.take(len(page))] {
+			err := r.render(i)
+			if nil != err {
+				return err
+			}
+		}
+		return limit.next()
+	})
+	if nil != err {
+		fatalPaging(err)
+	}
+
+	err = r.flush()
+	if nil != err {
+		fatalPaging(err)
+	}
+	stopPager()
+
+	err = r.printCount(limit.count, "issues")
+	if nil != err {
+		log.Fatal(err)
+	}
+}
+
+/// Show an issue in detail, or open it in the browser with --web
+func viewIssue(c *cli.Context, server gitlab, projectId string, i issue) error {
+	if c.Bool("web") {
+		browse(server.getIssueUrl(projectId, i.Iid))
+		return nil
+	}
+	if formatHelp(c, IssueViewTemplate, issueDetails{}) {
+		return nil
+	}
+
+	details := issueDetails{issue: i}
+	if count := c.Int("activity"); count > 0 {
+		notes, err := server.getIssueNotes(projectId, i.Iid)
+		if nil != err {
+			return err
+		}
+		details.Activity = recentNotes([]discussion{{Notes: notes}}, count)
+	}
+
+	r, err := newRenderer(c, "issue-view", IssueViewTemplate, issueColumns, false)
+	if nil != err {
+		return err
+	}
+
+	defer startPager(c)()
+	err = r.render(details)
+	if nil != err {
+		return err
+	}
+	return r.flush()
+}
+
+/// Create issue titled by the first argument, or write title and description in $EDITOR
+func createIssue(c *cli.Context) {
+	server := needGitlab(c)
+	server.token = needToken(c)
+	projectId := needRemoteUrl(c).path
+
+	title := strings.Join(c.Args(), " ")
+	var description string
+	var err error
+	switch {
+	case title == "":
+		title, description, err = editIssue("", "", "New issue in "+projectId)
+	case c.String("message") != "":
+		description, err = readMessage(c.String("message"), "Description of: "+title)
+	}
+	if nil != err {
+		log.Fatal(err)
+	}
+
+	options, err := issueRequestFromFlags(c, server, projectId, nil)
+	if nil != err {
+		log.Fatal(err)
+	}
+	options.Title = title
+	if description != "" {
+		options.Description = &description
+	}
+
+	created, err := server.createIssue(projectId, options)
+	if nil != err {
+		log.Fatal(err)
+	}
+
+	addr := server.getIssueUrl(projectId, created.Iid)
+	log.Println("Created issue:", addr)
+	if c.Bool("web") {
+		browse(addr)
+	}
+}
+
+/// Create and check out a branch for the issue, from the default branch of the remote unless --base is given
+func startIssue(c *cli.Context, server gitlab, projectId string, i issue) error {
+	branch := c.String("branch")
+	if branch != "" && !isValidBranchName(branch) {
+		return fmt.Errorf("Not a valid branch name: %q\n", branch)
+	}
+	if branch == "" {
+		config, err := readConfig(c)
+		if nil != err {
+			return err
+		}
+		branch, err = issueBranchName(config.IssueBranch, i)
+		if nil != err {
+			return err
+		}
+	}
+
+	gitDir := needGitDir(c)
+	if gitDir.hasBranch(branch) {
+		log.Printf("Branch %s exists, checking it out\n", branch)
+		return gitDir.run("checkout", branch)
+	}
+
+	base := c.String("base")
+	if base == "" {
+		p, err := server.getProject(projectId)
+		if nil != err {
+			return err
+		}
+		remote := c.String("remote")
+		err = gitDir.run("fetch", remote, p.DefaultBranch)
+		if nil != err {
+			return err
+		}
+		base = remote + "/" + p.DefaultBranch
+	}
+
+	log.Printf("Starting #%d on branch %s from %s\n", i.Iid, branch, base)
+	return gitDir.run("checkout", "--no-track", "-b", branch, base)
+}
+
+/// Branch name of an issue from a template, IssueBranchTemplate when empty
+func issueBranchName(format string, i issue) (string, error) {
+	if format == "" {
+		format = IssueBranchTemplate
+	}
+
+	tmpl, err := newMonochromeTemplate("issue-branch", format)
+	if nil != err {
+		return "", err
+	}
+	var name bytes.Buffer
+	err = tmpl.Execute(&name, i)
+	if nil != err {
+		return "", err
+	}
+
+	branch := strings.TrimSpace(name.String())
+	if !isValidBranchName(branch) {
+		return "", fmt.Errorf("Not a valid branch name: %q, check issue_branch of ~/.labrc or .lab\n", branch)
+	}
+	return branch, nil
+}
+
+/// Let a merge request close the issue, inheriting its title, labels and milestone
+func linkIssue(options *mergeRequestCreateRequest, i issue) {
+	if options.Title == "" {
+		options.Title = i.Title
+	}
+
+	closes := "Closes #" + strconv.Itoa(i.Iid)
+	if options.Description == "" {
+		options.Description = closes
+	} else if !strings.Contains(options.Description, closes) {
+		options.Description += "\n\n" + closes
+	}
+
+	if options.Labels == "" {
+		options.Labels = strings.Join(i.Labels, ",")
+	}
+	if options.MilestoneId == 0 && nil != i.Milestone {
+		options.MilestoneId = i.Milestone.Id
+	}
+}
+
+/// Update title, description, labels, assignees or milestone of an issue
+func updateIssue(c *cli.Context, server gitlab, projectId string, i issue) error {
+	options, err := issueRequestFromFlags(c, server, projectId, &i)
+	if nil != err {
+		return err
+	}
+
+	if c.IsSet("title") {
+		options.Title = c.String("title")
+	}
+	if c.IsSet("message") {
+		description, err := readMessage(c.String("message"), fmt.Sprintf("Description of #%d: %s", i.Iid, i.Title))
+		if nil != err {
+			return err
+		}
+		options.Description = &description
+	}
+	if c.Bool("edit") {
+		title, description, err := editIssue(i.Title, i.Description, fmt.Sprintf("Editing #%d", i.Iid))
+		if nil != err {
+			return err
+		}
+		options.Title = title
+		options.Description = &description
+	}
+
+	if options.Title == "" && nil == options.Description && nil == options.Labels &&
+		len(options.AssigneeIds) == 0 && nil == options.MilestoneId && nil == options.Confidential {
+		return fmt.Errorf("Nothing to update, see: lab issue update --help")
+	}
+
+	_, err = server.updateIssue(projectId, i.Iid, options)
+	if nil != err {
+		return err
+	}
+
+	log.Println("Updated issue:", server.getIssueUrl(projectId, i.Iid))
+	return nil
+}
+
+/// Close or reopen an issue, by ISSUE_STATE_EVENT_CLOSE or ISSUE_STATE_EVENT_REOPEN
+func changeIssueState(event string) func(*cli.Context, gitlab, string, issue) error {
+	return func(c *cli.Context, server gitlab, projectId string, i issue) error {
+		if message := c.String("message"); message != "" {
+			err := commentOnIssue(c, server, projectId, i)
+			if nil != err {
+				return err
+			}
+		}
+
+		updated, err := server.updateIssue(projectId, i.Iid, issueRequest{StateEvent: event})
+		if nil != err {
+			return err
+		}
+
+		log.Printf("Issue #%d is %s: %s\n", i.Iid, updated.State, server.getIssueUrl(projectId, i.Iid))
+		return nil
+	}
+}
+
+func commentOnIssue(c *cli.Context, server gitlab, projectId string, i issue) error {
+	message := c.Args().Get(1)
+	if message == "" {
+		message = c.String("message")
+	}
+
+	body, err := readMessage(message, fmt.Sprintf("Comment on #%d: %s", i.Iid, i.Title))
+	if nil != err {
+		return err
+	}
+
+	created, err := server.createIssueNote(projectId, i.Iid, body)
+	if nil != err {
+		return err
+	}
+
+	log.Println("Commented:", server.getIssueUrl(projectId, i.Iid)+"#note_"+strconv.Itoa(created.Id))
+	return nil
+}
+
+/// Browse issue by ID or of the current branch, or the issues of the project
+func browseIssue(c *cli.Context) {
+	server := needGitlab(c)
+	projectId := needRemoteUrl(c).path
+
+	issueId, err := issueIdFromContext(c)
+	if nil != err {
+		if c.Args().First() != "" {
+			log.Fatal(err)
+		}
+		browse(server.getProjectIssuesUrl(projectId))
+		return
+	}
+
+	browse(server.getIssueUrl(projectId, issueId))
+}
+
+/// Labels, assignees, milestone and confidentiality from flags, current is the issue being updated, if any
+func issueRequestFromFlags(c *cli.Context, server gitlab, projectId string, current *issue) (issueRequest, error) {
+	var options issueRequest
+
+	addLabels, removeLabels := c.StringSlice("add-label"), c.StringSlice("remove-label")
+	if c.IsSet("label") || len(addLabels) > 0 || len(removeLabels) > 0 {
+		labels := c.StringSlice("label")
+		if !c.IsSet("label") && nil != current {
+			labels = current.Labels
+		}
+		joined := strings.Join(editLabels(labels, addLabels, removeLabels), ",")
+		options.Labels = &joined
+	}
+
+	for _, username := range c.StringSlice("assignee") {
+		if strings.ToLower(username) == "none" {
+			// Unassigns everyone
+			options.AssigneeIds = []int{0}
+			break
+		}
+		u, err := server.findUser(strings.TrimPrefix(username, "@"))
+		if nil != err {
+			return options, err
+		}
+		options.AssigneeIds = append(options.AssigneeIds, u.Id)
+	}
+
+	if title := c.String("milestone"); title != "" {
+		milestoneId := 0
+		if strings.ToLower(title) != "none" {
+			m, err := server.findMilestone(projectId, title)
+			if nil != err {
+				return options, err
+			}
+			milestoneId = m.Id
+		}
+		options.MilestoneId = &milestoneId
+	}
+
+	switch {
+	case c.Bool("confidential") && c.Bool("public"):
+		return options, fmt.Errorf("Use either --confidential or --public\n")
+	case c.Bool("confidential"):
+		confidential := true
+		options.Confidential = &confidential
+	case c.Bool("public"):
+		confidential := false
+		options.Confidential = &confidential
+	}
+
+	return options, nil
+}
+
+/// Labels with those added and removed, keeping their order
+func editLabels(labels, add, remove []string) []string {
+	removed := make(map[string]bool)
+	for _, label := range remove {
+		removed[label] = true
+	}
+
+	var edited []string
+	seen := make(map[string]bool)
+	for _, label := range append(append([]string{}, labels...), add...) {
+		if !removed[label] && !seen[label] {
+			seen[label] = true
+			edited = append(edited, label)
+		}
+	}
+	return edited
+}
+
+/// Let the user write title and description in $EDITOR, the first line is the title
+func editIssue(title, description, help string) (string, string, error) {
+	initial := title
+	if description != "" {
+		initial += "\n\n" + description
+	}
+
+	message, err := editMessage(initial, help+"\n\nThe first line is the title, the rest the description.\nLines starting with '#' are ignored, an empty message aborts.")
+	if nil != err {
+		return "", "", err
+	}
+
+	lines := strings.SplitN(message, "\n", 2)
+	title = strings.TrimSpace(lines[0])
+	description = ""
+	if len(lines) > 1 {
+		description = strings.TrimSpace(lines[1])
+	}
+	return title, description, nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestIssueIdFromBranch(t *testing.T) {
+	for branch, expected := range map[string]int{
+		"42-fix-login":         42,
+		"feature/7-add-issues": 7,
+		"12":                   12,
+		"fix-42":               0,
+		"2fa-login":            0,
+		"master":               0,
+	} {
+		issueId, ok := issueIdFromBranch(branch)
+		if issueId != expected || ok != (expected > 0) {
+			t.Fatalf("Expected issue %d for branch %s, got: %d\n", expected, branch, issueId)
+		}
+	}
+}
+
+func TestEditLabels(t *testing.T) {
+	edited := editLabels([]string{"bug", "backend"}, []string{"urgent", "bug"}, []string{"backend"})
+	if !reflect.DeepEqual(edited, []string{"bug", "urgent"}) {
+		t.Fatal("Unexpected labels:", edited)
+	}
+}
+
+func TestIssueBranchName(t *testing.T) {
+	i := issue{Iid: 42, Title: "Login fails on Safari (v10.1)"}
+
+	branch, err := issueBranchName("", i)
+	if nil != err || branch != "42-login-fails-on-safari-v10-1" {
+		t.Fatal("Unexpected branch:", branch, err)
+	}
+
+	branch, err = issueBranchName("issue/{{ .Iid }}", i)
+	if nil != err || branch != "issue/42" {
+		t.Fatal("Unexpected branch:", branch, err)
+	}
+
+	for _, format := range []string{"{{ .Title }}", "{{ .Iid }}..x", "-{{ .Iid }}", "{{ .Iid }}.lock", "issue/{{ .Iid }}/", "{{ .Iid }}\x7f"} {
+		if branch, err := issueBranchName(format, i); nil == err {
+			t.Fatal("Expected error for invalid branch name:", branch)
+		}
+	}
+
+	long := issue{Iid: 7, Title: strings.Repeat("a", 300)}
+	branch, err = issueBranchName("", long)
+	if nil != err || len(branch) > 2+SLUG_MAX_LENGTH {
+		t.Fatal("Expected the long word of the title cut, got:", branch, err)
+	}
+}
+
+func TestLinkIssue(t *testing.T) {
+	options := mergeRequestCreateRequest{SourceBranch: "42-login-fails"}
+	linkIssue(&options, issue{Iid: 42, Title: "Login fails", Labels: []string{"bug", "ui"}, Milestone: &milestone{Id: 3}})
+
+	expected := mergeRequestCreateRequest{
+		SourceBranch: "42-login-fails",
+		Title:        "Login fails",
+		Description:  "Closes #42",
+		Labels:       "bug,ui",
+		MilestoneId:  3,
+	}
+	if options != expected {
+		t.Fatalf("Expected %+v, got: %+v\n", expected, options)
+	}
+}
@@ -1,13 +1,14 @@
 package main
 
 import (
-	"encoding/xml"
+	"bufio"
 	"fmt"
 	"github.com/BurntSushi/toml"
+	"github.com/andrew-d/go-termutil"
 	"github.com/codegangsta/cli"
-	"github.com/fatih/color"
 	"github.com/stackengine/gopass"
 	"log"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -19,39 +20,82 @@ func init() {
 }
 
 type config struct {
-	PrivateToken string `toml:"private_token"`
+	PrivateToken string            `toml:"private_token"`
+	Formats      map[string]string `toml:"formats,omitempty"`
+	Instances    []instanceConfig  `toml:"instances,omitempty"`
+	IssueBranch  string            `toml:"issue_branch,omitempty"`
 }
 
 // Create action for a particular merge request, defaulting to the current (by branch)
-func createActionForMergeRequest(callback func(gitlab, string, mergeRequest) error) func(*cli.Context) {
+func createActionForMergeRequest(callback func(*cli.Context, gitlab, string, mergeRequest) error) func(*cli.Context) {
+	return mergeRequestAction(callback, false)
+}
+
+// Create action for a particular merge request taking more arguments, see mergeRequestArgs,
+// a first argument that is not an ID is one of those for the current merge request
+func createActionForMergeRequestWithArgs(callback func(*cli.Context, gitlab, string, mergeRequest) error) func(*cli.Context) {
+	return mergeRequestAction(callback, true)
+}
+
+/// Digits of merge request IDs before other arguments, longer numbers are taken for abbreviated discussion ids
+const MERGE_REQUEST_ARG_MAX_DIGITS int = 6
+
+/// Arguments following the optional merge request ID
+func mergeRequestArgs(c *cli.Context) []string {
+	if _, ok := leadingMergeRequestId(c.Args().First()); ok {
+		return c.Args().Tail()
+	}
+	return c.Args()
+}
+
+/// Merge request ID of a first argument followed by others: "!12", or a number of up to MERGE_REQUEST_ARG_MAX_DIGITS
+func leadingMergeRequestId(arg string) (int, bool) {
+	digits := strings.TrimPrefix(arg, "!")
+	if digits == arg && len(arg) > MERGE_REQUEST_ARG_MAX_DIGITS {
+		return 0, false
+	}
+	id, err := strconv.Atoi(digits)
+	return id, nil == err
+}
+
+/// Run callback on the merge request by ID, of the current branch or picked, withArgs allowing other first arguments
+func mergeRequestAction(callback func(*cli.Context, gitlab, string, mergeRequest) error, withArgs bool) func(*cli.Context) {
 	return func(c *cli.Context) {
 		server := needGitlab(c)
 		remoteUrl := needRemoteUrl(c)
 		gitDir := needGitDir(c)
 		server.token = needToken(c)
 
-		mergeRequests, err := needMergeRequests(c)
-		if nil != err {
-			log.Fatal(err)
-		}
-
-		if c.Args().First() != "" {
-			mergeRequestId, err := strconv.Atoi(c.Args().First())
-			if err != nil {
+		mergeRequestId, isId := leadingMergeRequestId(c.Args().First())
+		if !withArgs {
+			var err error
+			mergeRequestId, err = strconv.Atoi(c.Args().First())
+			if nil != err && c.Args().First() != "" {
 				log.Fatalf("You did not provide a valid ID")
 			}
+			isId = nil == err
+		}
+		if isId {
+			// Find merged and closed merge requests by ID too, unless asked otherwise
+			state := c.String("state")
+			if !c.IsSet("state") {
+				state = "all"
+			}
+			request, err := server.findMergeRequest(remoteUrl.path, state, mergeRequestId)
+			if nil != err {
+				log.Fatal(err)
+			}
 
-			for _, request := range mergeRequests {
-				if request.Iid == mergeRequestId {
-					err := callback(server, remoteUrl.path, request)
-					if err != nil {
-						log.Fatal(err)
-					}
-					return
-				}
+			err = callback(c, server, remoteUrl.path, *request)
+			if err != nil {
+				fatalPaging(err)
 			}
+			return
+		}
 
-			log.Fatalf("Unable to find merge request with ID #%d\n", mergeRequestId)
+		mergeRequests, err := needMergeRequests(c)
+		if nil != err {
+			log.Fatal(err)
 		}
 
 		currentBranch, err := gitDir.getCurrentBranch()
@@ -61,64 +105,289 @@ func createActionForMergeRequest(callback func(gitlab, string, mergeRequest) err
 
 		for _, request := range mergeRequests {
 			if request.SourceBranch == currentBranch {
-				err := callback(server, remoteUrl.path, request)
+				err := callback(c, server, remoteUrl.path, request)
 				if err != nil {
-					log.Fatal(err)
+					fatalPaging(err)
 				}
 				return
 			}
 		}
 
+		// Let the user pick one instead, when asking is possible
+		if termutil.Isatty(os.Stdin.Fd()) {
+			log.Printf("No merge request for branch: %s, pick one\n", currentBranch)
+			request := pickMergeRequest(c, mergeRequests)
+			if nil == request {
+				return
+			}
+			err := callback(c, server, remoteUrl.path, *request)
+			if err != nil {
+				fatalPaging(err)
+			}
+			return
+		}
+
 		log.Fatalf("Could not find merge request for branch: %s on project %s\n", currentBranch, remoteUrl.path)
 	}
 }
 
+/// Let the user pick one of the merge requests of the flags, nil if none was picked
 func promptForMergeRequest(c *cli.Context) *mergeRequest {
-	remoteUrl := needRemoteUrl(c)
-	state := c.String("state")
-	server := needGitlab(c)
-	token := needToken(c)
-	server.token = token
+	mergeRequests, err := needMergeRequests(c)
+	if nil != err {
+		log.Fatal(err)
+	}
+	return pickMergeRequest(c, mergeRequests)
+}
+
+/// Let the user pick a merge request, labeled by --format and previewed with its description
+func pickMergeRequest(c *cli.Context, mergeRequests []mergeRequest) *mergeRequest {
+	if len(mergeRequests) == 0 {
+		log.Println("No merge requests to pick from")
+		return nil
+	}
 
-	format := c.String("format")
-	if format == "" {
-		format = MergeRequestCheckoutListTemplate
+	format, err := resolveFormat(c, MergeRequestCheckoutListTemplate)
+	if nil != err {
+		log.Fatal(err)
 	}
-	tmpl, err := newColorTemplate("default-merge-request-list-template", format)
+	items, err := mergeRequestPickerItems(format, mergeRequests)
 	if nil != err {
 		log.Fatal(err)
 	}
 
-	mergeRequests, err := server.queryMergeRequests(remoteUrl.path, state)
+	i, err := pick("Merge request", items)
+	if err == ErrNoSelection {
+		return nil
+	}
 	if nil != err {
 		log.Fatal(err)
 	}
-	for i, request := range mergeRequests {
-		fmt.Fprintf(os.Stderr, color.RedString("%%d: "), i)
-		err = tmpl.Execute(os.Stderr, request)
-		if err != nil {
-			log.Fatal(err)
+	return &mergeRequests[i]
+}
+
+/// Ask a yes/no question on stderr, anything but yes (or EOF) means no
+func confirm(question string) bool {
+	fmt.Fprintf(os.Stderr, "%s [y/N]: ", question)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if nil != err && answer == "" {
+		return false
+	}
+
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+/// Accept merge request after checking pipeline, conflicts and approvals
+func acceptMergeRequest(c *cli.Context, server gitlab, projectId string, req mergeRequest) error {
+	request, err := server.getMergeRequest(projectId, req.Iid)
+	if nil != err {
+		return err
+	}
+
+	request.Approvals, err = server.getMergeRequestApprovals(projectId, req.Iid)
+	if nil != err {
+		return err
+	}
+
+	options := mergeRequestAcceptRequest{
+		MergeCommitMessage:        c.String("merge-commit-message"),
+		ShouldRemoveSourceBranch:  !c.Bool("keep-branch"),
+		MergeWhenPipelineSucceeds: c.Bool("when-pipeline-succeeds"),
+		Squash:                    c.Bool("squash"),
+	}
+
+	if sha := c.String("sha"); sha != "" {
+		if !strings.HasPrefix(request.Sha, sha) {
+			return fmt.Errorf("Source branch has moved: expected %s, HEAD is %s\n", sha, request.Sha)
 		}
+		options.Sha = request.Sha
+	}
+
+	tmpl, err := newTemplate("merge-request-accept", MergeRequestAcceptTemplate, doColors(os.Stderr))
+	if nil != err {
+		return err
+	}
+	err = tmpl.Execute(os.Stderr, struct {
+		*mergeRequest
+		Options mergeRequestAcceptRequest
+	}{request, options})
+	if nil != err {
+		return err
+	}
+
+	if blockers := request.mergeBlockers(); len(blockers) > 0 {
+		return fmt.Errorf("Refusing to accept merge request:\n\t - %s\n", strings.Join(blockers, "\n\t - "))
+	}
+
+	if !c.Bool("yes") && !confirm("Accept merge request?") {
+		return fmt.Errorf("Aborted, use --yes to accept without confirmation")
+	}
+
+	merged, err := server.acceptMergeRequest(projectId, req.Iid, options)
+	if nil != err {
+		return err
 	}
 
-	// Prompt for id
-	var mergeRequest mergeRequest
-	for {
-		fmt.Fprintf(os.Stderr, "Select a merge request: ")
-		var id int
-		_, err = fmt.Scanf("%d", &id)
+	if merged.State == "merged" {
+		log.Println("Merged:", server.getMergeRequestUrl(projectId, req.Iid))
+	} else {
+		log.Println("Merge when pipeline succeeds:", server.getMergeRequestUrl(projectId, req.Iid))
+	}
+	return nil
+}
+
+/// Show required and given approvals of a merge request
+func showApprovals(c *cli.Context, server gitlab, projectId string, req mergeRequest) error {
+	var err error
+	req.Approvals, err = server.getMergeRequestApprovals(projectId, req.Iid)
+	if nil != err {
+		return err
+	}
+
+	r, err := newRenderer(c, "merge-request-approvals", MergeRequestApprovalsTemplate, nil, false)
+	if nil != err {
+		return err
+	}
+
+	err = r.render(req)
+	if nil != err {
+		return err
+	}
+	return r.flush()
+}
+
+func approveMergeRequest(c *cli.Context, server gitlab, projectId string, req mergeRequest) error {
+	approvals, err := server.approveMergeRequest(projectId, req.Iid, c.String("sha"))
+	if nil != err {
+		return err
+	}
+
+	log.Printf("Approved !%d, %d approvals left\n", req.Iid, approvals.ApprovalsLeft)
+	return nil
+}
+
+func unapproveMergeRequest(c *cli.Context, server gitlab, projectId string, req mergeRequest) error {
+	err := server.unapproveMergeRequest(projectId, req.Iid)
+	if nil != err {
+		return err
+	}
+
+	log.Printf("Withdrew approval of !%d\n", req.Iid)
+	return nil
+}
+
+/// Diff merge request from its diff refs, or those of a specific version
+func diffMergeRequest(c *cli.Context, server gitlab, projectId string, req mergeRequest) error {
+	var base, head string
+	if version := c.Int("version"); version != 0 {
+		v, err := server.getMergeRequestVersion(projectId, req.Iid, version)
 		if nil != err {
-			continue
+			return err
 		}
-		if id > len(mergeRequests)-1 {
-			continue
+		base, head = v.BaseCommitSha, v.HeadCommitSha
+	} else {
+		request, err := server.getMergeRequest(projectId, req.Iid)
+		if nil != err {
+			return err
+		}
+		if nil == request.DiffRefs {
+			return fmt.Errorf("Merge request !%d has no diff refs\n", req.Iid)
+		}
+		base, head = request.DiffRefs.BaseSha, request.DiffRefs.HeadSha
+	}
+
+	gitDir := needGitDir(c)
+	err := gitDir.fetchMergeRequest(c.String("remote"), req.Iid, base, head)
+	if nil != err {
+		return err
+	}
+
+	if c.Bool("difftool") || c.String("tool") != "" {
+		return gitDir.difftool(base, head, c.String("tool"))
+	}
+
+	var args []string
+	if c.Bool("stat") {
+		args = append(args, "--stat")
+	}
+	if c.Bool("name-only") {
+		args = append(args, "--name-only")
+	}
+
+	defer startPager(c)()
+	if doColors(os.Stdout) {
+		args = append(args, "--color=always")
+	}
+	return gitDir.diff(base, head, args...)
+}
+
+/// Check out merge request head into a local branch tracking the source branch
+func checkoutMergeRequest(c *cli.Context, server gitlab, projectId string, req mergeRequest) error {
+	gitDir := needGitDir(c)
+	remote := c.String("remote")
+
+	err := gitDir.fetchMergeRequest(remote, req.Iid)
+	if nil != err {
+		return err
+	}
+	sha, err := gitDir.revParse("FETCH_HEAD")
+	if nil != err {
+		return err
+	}
+
+	if c.Bool("detach") {
+		log.Printf("Checking out !%d at %s\n", req.Iid, sha)
+		return gitDir.checkoutMergeRequest("", sha, c.String("worktree"))
+	}
+
+	// The source branch lives on the fork for merge requests from forks
+	upstreamRemote := remote
+	if req.fromFork() {
+		source, err := server.getProject(strconv.Itoa(req.SourceProjectId))
+		if nil != err {
+			return err
+		}
+
+		sourceUrl := source.SshUrlToRepo
+		if remoteUrl, err := gitDir.getRemoteUrl(remote); nil == err && strings.HasPrefix(remoteUrl, "http") {
+			sourceUrl = source.HttpUrlToRepo
 		}
 
-		mergeRequest = mergeRequests[id]
-		break
+		upstreamRemote, err = gitDir.ensureRemote(source.Namespace.Path, sourceUrl)
+		if nil != err {
+			return err
+		}
 	}
 
-	return &mergeRequest
+	// Fails when the source branch has been removed, eg. after merging
+	fetchErr := gitDir.run(
+		"fetch",
+		upstreamRemote,
+		fmt.Sprintf("+refs/heads/%s:refs/remotes/%s/%s", req.SourceBranch, upstreamRemote, req.SourceBranch),
+	)
+
+	branch := c.String("branch")
+	if branch == "" {
+		branch = req.SourceBranch
+	}
+
+	err = gitDir.createOrFastForwardBranch(branch, sha)
+	if nil != err {
+		return err
+	}
+
+	if nil == fetchErr {
+		err = gitDir.run("branch", "--set-upstream-to="+upstreamRemote+"/"+req.SourceBranch, branch)
+		if nil != err {
+			return err
+		}
+	} else {
+		log.Printf("Could not fetch source branch %s, not setting upstream\n", req.SourceBranch)
+	}
+
+	log.Printf("Checking out !%d as %s\n", req.Iid, branch)
+	return gitDir.checkoutMergeRequest(branch, sha, c.String("worktree"))
 }
 
 /// Browse a url, x or text
@@ -132,25 +401,155 @@ func browse(url string) {
 
 /// Get gitlab merge requests or fail!
 func needMergeRequests(c *cli.Context) ([]mergeRequest, error) {
-	token := needToken(c)
+	var mergeRequests []mergeRequest
+	err := needMergeRequestPages(c, false, func(page []mergeRequest) error {
+		mergeRequests = append(mergeRequests, page...)
+		return nil
+	})
+
+	return mergeRequests, err
+}
+
+/// Limit of items to take of pages by --limit, none when 0
+type pageLimit struct {
+	limit int
+	count int
+}
+
+/// Get the --limit of paging, asking for smaller pages of query when fewer items are wanted
+func newPageLimit(c *cli.Context, query url.Values) *pageLimit {
+	l := &pageLimit{limit: c.Int("limit")}
+	if l.limit > 0 && l.limit < PAGE_SIZE {
+		query.Set("per_page", strconv.Itoa(l.limit))
+	}
+	return l
+}
+
+/// Number of items to take of a page of n items
+func (l *pageLimit) take(n int) int {
+	if l.limit > 0 && l.count+n > l.limit {
+		n = l.limit - l.count
+	}
+	l.count += n
+	return n
+}
+
+/// ErrStopPaging once the limit is reached
+func (l *pageLimit) next() error {
+	if l.limit > 0 && l.count >= l.limit {
+		return ErrStopPaging
+	}
+	return nil
+}
+
+/// Get merge requests page by page as filtered by flags, up to --limit, across projects for sweep, see mr list --all
+func needMergeRequestPages(c *cli.Context, sweep bool, each func([]mergeRequest) error) error {
 	server := needGitlab(c)
-	server.token = token
+	server.token = needToken(c)
+
+	query, err := queryFromFlags(c, server)
+	if nil != err {
+		return err
+	}
+
+	limit := newPageLimit(c, query)
+	limited := func(page []mergeRequest) error {
+		err := each(page[:limit.take(len(page))])
+		if nil != err {
+			return err
+		}
+		return limit.next()
+	}
+
+	if sweep {
+		return eachSweepMergeRequestPage(c, server, query, limited)
+	}
+
+	return server.eachMergeRequestPage(needRemoteUrl(c).path, query, limited)
+}
+
+/// Get merge requests across projects: assigned to me or awaiting my review, unless filtered otherwise
+func eachSweepMergeRequestPage(c *cli.Context, server gitlab, query url.Values, each func([]mergeRequest) error) error {
+	query.Set("scope", "all")
+
+	group := c.String("group")
+	for _, key := range []string{"author_id", "author_username", "assignee_id", "assignee_username", "reviewer_id", "reviewer_username"} {
+		if query.Get(key) != "" {
+			return server.eachGlobalMergeRequestPage(group, query, each)
+		}
+	}
+
+	me, err := server.getCurrentUser()
+	if nil != err {
+		return err
+	}
+
+	seen := make(map[int]bool)
+	stopped := false
+	for _, key := range []string{"assignee_id", "reviewer_id"} {
+		if stopped {
+			break
+		}
 
-	remoteUrl := needRemoteUrl(c)
-	state := c.String("state")
+		mine := url.Values{}
+		for k, v := range query {
+			mine[k] = v
+		}
+		mine.Set(key, strconv.Itoa(me.Id))
+
+		err := server.eachGlobalMergeRequestPage(group, mine, func(page []mergeRequest) error {
+			var unseen []mergeRequest
+			for _, request := range page {
+				if !seen[request.Id] {
+					seen[request.Id] = true
+					unseen = append(unseen, request)
+				}
+			}
 
-	return server.queryMergeRequests(remoteUrl.path, state)
+			err := each(unseen)
+			stopped = err == ErrStopPaging
+			return err
+		})
+		if nil != err {
+			return err
+		}
+	}
+
+	return nil
 }
 
 /// Get gitlab url or fail!
 func needGitlab(c *cli.Context) gitlab {
 	r := needRemoteUrl(c)
+	if !isGitlabHost(r.base) {
+		fatalf("Gitlab server on: \"%s\"? I don't think so\n", r.base)
+	}
+	return newGitlab(r.base)
+}
+
+/// Exit code of fatal errors, commands with exit codes of their own use another
+var fatalExitCode = 1
+
+/// Like log.Fatal, exiting with fatalExitCode
+func fatal(v ...interface{}) {
+	log.Output(2, fmt.Sprint(v...))
+	os.Exit(fatalExitCode)
+}
+
+/// Like log.Fatalf, exiting with fatalExitCode
+func fatalf(format string, v ...interface{}) {
+	log.Output(2, fmt.Sprintf(format, v...))
+	os.Exit(fatalExitCode)
+}
+
+/// Tell hosting sites that are known not to be GitLab
+func isGitlabHost(base string) bool {
 	for _, host := range []string{"github.com", "code.google.com", "bitbucket.org"} {
-		if strings.HasSuffix(r.base, host) {
-			log.Fatalf("Gitlab server on: \"%s\"? I don't think so\n", r.base)
+		if strings.HasSuffix(base, host) {
+			return false
 		}
 	}
-	return newGitlab(r.base)
+	return true
 }
 
 func needGitDir(c *cli.Context) gitDir {
@@ -159,7 +558,7 @@ func needGitDir(c *cli.Context) gitDir {
 	if given == "" {
 		given, err = os.Getwd()
 		if err != nil {
-			log.Fatal(err)
+			fatal(err)
 		}
 	}
 
@@ -172,7 +571,7 @@ func needRemoteUrl(c *cli.Context) gitRemote {
 	git := needGitDir(c)
 	remoteUrl, err := git.getRemoteUrl(remote)
 	if nil != err {
-		log.Fatal(err)
+		fatal(err)
 	}
 
 	return parseRemote(remoteUrl)
@@ -185,7 +584,7 @@ func needToken(c *cli.Context) string {
 	gitDir := needGitDir(c)
 	wd, err := gitDir.Getwd()
 	if nil != err {
-		log.Fatal(err)
+		fatal(err)
 	}
 
 	projectLabFile := filepath.Join(wd, ".lab")
@@ -198,7 +597,7 @@ func needToken(c *cli.Context) string {
 			if os.IsNotExist(err) {
 				// ~/.labrc does not exist, move on
 			} else {
-				log.Fatalf("%T\n", err)
+				fatalf("%T\n", err)
 			}
 		}
 
@@ -228,14 +627,14 @@ func needToken(c *cli.Context) string {
 
 			session, err := server.getSession(login, password)
 			if err != nil {
-				log.Fatal(err)
+				fatal(err)
 			}
 			token = session.PrivateToken
 
 			// Write to $PROJECT/.lab
 			f, err := os.OpenFile(projectLabFile, os.O_TRUNC|os.O_WRONLY|os.O_CREATE, 0600)
 			if nil != err {
-				log.Fatal(err)
+				fatal(err)
 			}
 			defer f.Close()
 
@@ -243,7 +642,7 @@ func needToken(c *cli.Context) string {
 			enc := toml.NewEncoder(f)
 			err = enc.Encode(config)
 			if nil != err {
-				log.Fatal(err)
+				fatal(err)
 			}
 			fmt.Fprintf(os.Stderr, "Saved private token to %s\n", projectLabFile)
 		}
@@ -252,7 +651,7 @@ func needToken(c *cli.Context) string {
 	// Use token from arguments or environment
 	if token == "" {
 		server := needGitlab(c)
-		log.Fatal(
+		fatal(
 			"Could not get api token, get one from: \"",
 			server.getPrivateTokenUrl(),
 			"\n\nexport as LAB_PRIVATE_TOKEN or use as flag: --token <token>",
@@ -262,6 +661,11 @@ func needToken(c *cli.Context) string {
 	return token
 }
 
+/// Extend a set of flags without sharing the backing array of base
+func extendFlags(base []cli.Flag, extra ...cli.Flag) []cli.Flag {
+	return append(append([]cli.Flag{}, base...), extra...)
+}
+
 func main() {
 
 	app := cli.NewApp()
@@ -283,7 +687,20 @@ func main() {
 			EnvVar: "LAB_PRIVATE_TOKEN",
 		},
 		cli.StringFlag{
-			Name: "format, f",
+			Name:  "format, f",
+			Usage: "Go template, @name of a format in [formats] of ~/.labrc or .lab, or help to list fields",
+		},
+		cli.StringFlag{
+			Name:  "output, o",
+			Usage: "Output as: template, table, json, yaml, csv or tsv, default: table for lists on a terminal",
+		},
+		cli.StringFlag{
+			Name:  "columns",
+			Usage: "Columns for table, csv and tsv output, eg. iid,title,author.username",
+		},
+		cli.BoolFlag{
+			Name:  "no-pager",
+			Usage: "Do not page long output through $LAB_PAGER, $PAGER, core.pager or less -FRX",
 		},
 	}
 
@@ -292,6 +709,29 @@ func main() {
 		Value: "opened",
 	})
 
+	diffFlags := []cli.Flag{
+		cli.BoolFlag{
+			Name:  "stat",
+			Usage: "Show diffstat instead of the patch",
+		},
+		cli.BoolFlag{
+			Name:  "name-only",
+			Usage: "Show only names of changed files",
+		},
+		cli.IntFlag{
+			Name:  "version",
+			Usage: "Merge request version to diff, default: latest",
+		},
+		cli.BoolFlag{
+			Name:  "difftool",
+			Usage: "Open the diff with git difftool",
+		},
+		cli.StringFlag{
+			Name:  "tool",
+			Usage: "Difftool to use, implies --difftool",
+		},
+	}
+
 	app.Commands = []cli.Command{
 		{
 			Name:  "browse",
@@ -304,70 +744,111 @@ func main() {
 				browse(addr)
 			},
 		},
+		{
+			Name:  "tui",
+			Usage: "Dashboard of merge requests, pipelines and activity of the project",
+			// Options of the mr actions run from the dashboard
+			Flags: extendFlags(extendFlags(flags, diffFlags...),
+				cli.IntFlag{
+					Name:  "activity",
+					Value: 5,
+					Usage: "Number of recent notes to show when viewing, 0 for none",
+				},
+				cli.BoolFlag{
+					Name:  "squash",
+					Usage: "Squash commits into a single commit when accepting",
+				},
+				cli.BoolFlag{
+					Name:  "keep-branch",
+					Usage: "Do not remove the source branch when accepting",
+				},
+				cli.BoolFlag{
+					Name:  "when-pipeline-succeeds",
+					Usage: "Merge when the pipeline succeeds when accepting",
+				},
+			),
+			Action: showDashboard,
+		},
+		{
+			Name:  "serve",
+			Usage: "Web dashboard of merge requests, pipelines and activity on localhost",
+			Flags: extendFlags(flags,
+				cli.StringFlag{
+					Name:  "listen",
+					Value: "localhost:7070",
+					Usage: "Address to listen on, localhost only",
+				},
+				cli.IntFlag{
+					Name:  "refresh",
+					Value: 60,
+					Usage: "Seconds to keep the dashboard before loading it again",
+				},
+				cli.BoolFlag{
+					Name:  "open",
+					Usage: "Open the dashboard in the browser",
+				},
+			),
+			Action: serveDashboard,
+		},
 		{
 			Name:  "feed",
 			Usage: "Get your GitLab feed",
 			Flags: flags,
 			Action: func(c *cli.Context) {
+				if formatHelp(c, FeedTemplate, feedCommit{}) {
+					return
+				}
+
 				server := needGitlab(c)
 				token := needToken(c)
 				server.token = token
 
-				feedUrl := server.getFeedUrl()
-
-				contents, err := server.buildFeed("GET", feedUrl, nil)
+				activity, err := server.getActivityFeed(server.getFeedUrl())
 				if err != nil {
-					log.Fatal("%s", err)
-				}
-
-				var activity activityFeed
-
-				sanitizedContents := strings.Replace(string(contents), "<img", "&lt;img", -1)
-
-				err = xml.Unmarshal([]byte(sanitizedContents), &activity)
-
-				if err != nil {
-					log.Fatal("%s", err)
+					log.Fatal(err)
 				}
 
 				commits := activity.Entries
+				defer startPager(c)()
 
-				// templating - feed title
-
-				formatTitle := c.String("format")
-				if formatTitle == "" {
-					formatTitle = FeedTitleTemplate
-				}
-
-				titleTmpl, err := newTemplate("title-feed", formatTitle, doColors(os.Stdout))
+				r, err := newRenderer(c, "default-feed", FeedTemplate, []string{"updated", "title"}, true)
 				if nil != err {
-					log.Fatal(err)
+					fatalPaging(err)
 				}
 
-				err = titleTmpl.Execute(os.Stdout, activity)
-				if err != nil {
-					log.Fatal(err)
-				}
+				// templating - feed title
 
-				// templating - feed entry
+				if r.isTemplate() {
+					formatTitle, err := resolveFormat(c, FeedTitleTemplate)
+					if nil != err {
+						fatalPaging(err)
+					}
 
-				format := c.String("format")
-				if format == "" {
-					format = FeedTemplate
-				}
+					titleTmpl, err := newTemplate("title-feed", formatTitle, doColors(os.Stdout))
+					if nil != err {
+						fatalPaging(err)
+					}
 
-				tmpl, err := newTemplate("default-feed", format, doColors(os.Stdout))
-				if nil != err {
-					log.Fatal(err)
+					err = titleTmpl.Execute(os.Stdout, activity)
+					if err != nil {
+						fatalPaging(err)
+					}
 				}
 
+				// feed entries
+
 				for _, commit := range commits {
-					err = tmpl.Execute(os.Stdout, commit)
+					err = r.render(commit)
 					if err != nil {
-						log.Fatal(err)
+						fatalPaging(err)
 					}
 				}
 
+				err = r.flush()
+				if nil != err {
+					fatalPaging(err)
+				}
+
 				return
 			},
 		},
@@ -380,7 +861,12 @@ func main() {
 					Name:      "create",
 					ShortName: "c",
 					Usage:     "Create merge request, default target branch: master.",
-					Flags:     flags,
+					Flags: extendFlags(flags,
+						cli.BoolFlag{
+							Name:  "no-issue",
+							Usage: "Do not link the issue the branch is named after, eg. 42 for 42-fix-login",
+						},
+					),
 					Action: func(c *cli.Context) {
 						server := needGitlab(c)
 						token := needToken(c)
@@ -399,14 +885,30 @@ func main() {
 							targetBranch = "master"
 						}
 
-						title := args.Get(1)
-						if title == "" {
+						options := mergeRequestCreateRequest{
+							SourceBranch: currentBranch,
+							TargetBranch: targetBranch,
+							Title:        args.Get(1),
+						}
+
+						// Close the issue the branch was started for, see: lab issue start
+						if issueId, ok := issueIdFromBranch(currentBranch); ok && !c.Bool("no-issue") {
+							linked, err := server.findIssue(remoteUrl.path, issueId)
+							if nil != err {
+								log.Println("Not linking issue:", strings.TrimSpace(err.Error()))
+							} else {
+								linkIssue(&options, *linked)
+								log.Printf("Linking issue #%d: %s\n", linked.Iid, linked.Title)
+							}
+						}
+
+						if options.Title == "" {
 							// Generate auto title
-							title = strings.Replace(currentBranch, "-", " ", -1)
-							title = strings.Replace(title, "_", " ", -1)
+							options.Title = strings.Replace(currentBranch, "-", " ", -1)
+							options.Title = strings.Replace(options.Title, "_", " ", -1)
 						}
 
-						createdMergeRequest, err := server.createMergeRequest(remoteUrl.path, currentBranch, targetBranch, title)
+						createdMergeRequest, err := server.createMergeRequest(remoteUrl.path, options)
 						if nil != err {
 							log.Fatal(err)
 						}
@@ -421,130 +923,249 @@ func main() {
 					ShortName: "b",
 					Usage:     "Browse current merge request or by ID.",
 					Flags:     mergeRequestFlags,
-					Action: createActionForMergeRequest(func(server gitlab, projectId string, req mergeRequest) error {
+					Action: createActionForMergeRequest(func(c *cli.Context, server gitlab, projectId string, req mergeRequest) error {
 						browse(server.getMergeRequestUrl(projectId, req.Iid))
 						return nil
 					}),
 				},
+				{
+					Name:  "view",
+					Usage: "Show current merge request or by ID in detail.",
+					Flags: extendFlags(mergeRequestFlags,
+						cli.BoolFlag{
+							Name:  "web, w",
+							Usage: "Open in the browser, like browse",
+						},
+						cli.IntFlag{
+							Name:  "activity",
+							Value: 5,
+							Usage: "Number of recent notes to show, 0 for none",
+						},
+					),
+					Action: createActionForMergeRequest(viewMergeRequest),
+				},
 				{
 					Name:  "accept",
 					Usage: "Accept current merge request or by ID.",
-					Flags: mergeRequestFlags,
-					Action: createActionForMergeRequest(func(server gitlab, projectId string, req mergeRequest) error {
-						err := server.acceptMergeRequest(projectId, req.Id)
-						if nil != err {
-							return err
-						}
-
-						// Delete source branch
-						log.Println("Removing source branch:", req.SourceBranch)
-						err = server.removeBranch(projectId, req.SourceBranch)
-						if nil != err {
-							return err
-						}
-
-						browse(server.getMergeRequestUrl(projectId, req.Iid))
-						return nil
-					}),
+					Flags: extendFlags(mergeRequestFlags,
+						cli.BoolFlag{
+							Name:  "yes, y",
+							Usage: "Accept without confirmation",
+						},
+						cli.BoolFlag{
+							Name:  "squash",
+							Usage: "Squash commits into a single commit",
+						},
+						cli.StringFlag{
+							Name:  "merge-commit-message, m",
+							Usage: "Custom merge commit message",
+						},
+						cli.BoolFlag{
+							Name:  "keep-branch",
+							Usage: "Do not remove the source branch",
+						},
+						cli.StringFlag{
+							Name:  "sha",
+							Usage: "Only accept if the source branch HEAD matches this SHA",
+						},
+						cli.BoolFlag{
+							Name:  "when-pipeline-succeeds",
+							Usage: "Merge when the pipeline succeeds",
+						},
+					),
+					Action: createActionForMergeRequest(acceptMergeRequest),
+				},
+				{
+					Name:  "approve",
+					Usage: "Approve current merge request or by ID.",
+					Flags: extendFlags(mergeRequestFlags,
+						cli.StringFlag{
+							Name:  "sha",
+							Usage: "Only approve if the source branch HEAD matches this SHA",
+						},
+					),
+					Action: createActionForMergeRequest(approveMergeRequest),
+				},
+				{
+					Name:   "unapprove",
+					Usage:  "Withdraw approval of current merge request or by ID.",
+					Flags:  mergeRequestFlags,
+					Action: createActionForMergeRequest(unapproveMergeRequest),
 				},
 				{
-					Name:  "diff",
-					Usage: "Diff current merge request or by ID.",
+					Name:  "approvals",
+					Usage: "Show approvals of current merge request or by ID.",
 					Flags: mergeRequestFlags,
 					Action: func(c *cli.Context) {
-						server := needGitlab(c)
-						remoteUrl := needRemoteUrl(c)
-						gitDir := needGitDir(c)
-
-						mergeRequests, err := needMergeRequests(c)
-						if nil != err {
-							log.Fatal(err)
-						}
-
-						if c.Args().First() != "" {
-							mergeRequestId, err := strconv.Atoi(c.Args().First())
-							if err != nil {
-								log.Fatalf("You did not provide a valid ID")
-							}
-
-							for _, request := range mergeRequests {
-								if request.Iid == mergeRequestId {
-									gitDir.diff2(request.TargetBranch, request.SourceBranch)
-									browse(server.getMergeRequestUrl(remoteUrl.path, mergeRequestId))
-									return
-								}
-							}
-
-							log.Fatalf("Unable to find merge request with ID #%d\n", mergeRequestId)
-						}
-
-						currentBranch, err := gitDir.getCurrentBranch()
-						if nil != err {
-							log.Fatal(err)
-						}
-
-						for _, request := range mergeRequests {
-							if request.SourceBranch == currentBranch {
-								gitDir.diff2(request.TargetBranch, request.SourceBranch)
-								return
-							}
+						if formatHelp(c, MergeRequestApprovalsTemplate, mergeRequest{}) {
+							return
 						}
-
-						log.Fatalf("Could not find merge request for branch: %s on project %s\n", currentBranch, remoteUrl.path)
+						createActionForMergeRequest(showApprovals)(c)
 					},
 				},
+				{
+					Name:  "comments",
+					Usage: "Show discussions of current merge request or by ID.",
+					Flags: extendFlags(mergeRequestFlags,
+						cli.BoolFlag{
+							Name:  "unresolved",
+							Usage: "Only show unresolved threads",
+						},
+						cli.BoolFlag{
+							Name:  "system",
+							Usage: "Include system notes",
+						},
+					),
+					Action: createActionForMergeRequest(showDiscussions),
+				},
+				{
+					Name:  "comment",
+					Usage: "Comment on current merge request or by ID: [id] [message], from stdin or $EDITOR",
+					Flags: extendFlags(mergeRequestFlags,
+						cli.StringFlag{
+							Name:  "message, m",
+							Usage: "Comment text, use - to read from stdin",
+						},
+					),
+					Action: createActionForMergeRequestWithArgs(commentOnMergeRequest),
+				},
+				{
+					Name:  "resolve",
+					Usage: "Resolve discussions of current merge request or by ID: [id] <discussion>...",
+					Flags: extendFlags(mergeRequestFlags,
+						cli.StringSliceFlag{
+							Name:  "discussion, d",
+							Usage: "Discussion to resolve, by (abbreviated) id",
+						},
+						cli.BoolFlag{
+							Name:  "all",
+							Usage: "Resolve all threads",
+						},
+						cli.BoolFlag{
+							Name:  "unresolve",
+							Usage: "Reopen threads instead",
+						},
+					),
+					Action: createActionForMergeRequestWithArgs(resolveDiscussions),
+				},
+				{
+					Name:  "review",
+					Usage: "Review current merge request or by ID, commenting on lines of the diff.",
+					Flags: extendFlags(mergeRequestFlags,
+						cli.IntFlag{
+							Name:  "version",
+							Usage: "Merge request version to review, default: latest",
+						},
+						cli.StringFlag{
+							Name:  "verdict",
+							Usage: "Finish with: approve, request-changes, comment, keep or discard",
+						},
+					),
+					Action: createActionForMergeRequest(reviewMergeRequest),
+				},
+				{
+					Name:   "diff",
+					Usage:  "Diff current merge request or by ID.",
+					Flags:  extendFlags(mergeRequestFlags, diffFlags...),
+					Action: createActionForMergeRequest(diffMergeRequest),
+				},
 				{
 					Name:  "pick-diff",
 					Usage: "Pick diff from merge requests",
-					Flags: mergeRequestFlags,
+					Flags: extendFlags(mergeRequestFlags, diffFlags...),
 					Action: func(c *cli.Context) {
-						gitDir := needGitDir(c)
-
 						request := promptForMergeRequest(c)
 						if nil == request {
 							return
 						}
 
-						gitDir.diff2(request.TargetBranch, request.SourceBranch)
+						server := needGitlab(c)
+						server.token = needToken(c)
+						err := diffMergeRequest(c, server, needRemoteUrl(c).path, *request)
+						if nil != err {
+							log.Fatal(err)
+						}
 					},
 				},
 				{
 					Name:      "list",
 					ShortName: "l",
 					Usage:     "List merge requests",
-					Flags:     mergeRequestFlags,
+					Flags: extendFlags(mergeRequestFlags, extendFlags(mergeRequestQueryFlags,
+						cli.BoolFlag{
+							Name:  "all, a",
+							Usage: "Merge requests of all projects, default: assigned to me or awaiting my review",
+						},
+						cli.StringFlag{
+							Name:  "group, g",
+							Usage: "Merge requests of all projects in a group, like --all",
+						},
+						cli.IntFlag{
+							Name:  "limit",
+							Usage: "Maximum number of merge requests, default: all",
+						},
+					)...),
 					Action: func(c *cli.Context) {
-						mergeRequests, err := needMergeRequests(c)
+						if formatHelp(c, MergeRequestListTemplate, mergeRequest{}) {
+							return
+						}
+
+						r, err := newRenderer(c, "default-merge-request", MergeRequestListTemplate, mergeRequestColumns, true)
 						if nil != err {
 							log.Fatal(err)
 						}
-
-						format := c.String("format")
-						if format == "" {
-							format = MergeRequestListTemplate
+						sweep := c.Bool("all") || c.String("group") != ""
+						tableColumns := mergeRequestTableDefaultColumns
+						if sweep {
+							tableColumns = append([]string{"project"}, tableColumns...)
 						}
+						r.withTable(c, mergeRequestTableColumns, tableColumns)
 
-						if format == "help" {
-							fmt.Println(MergeRequestListTemplate)
-							return
-						}
+						// Approvals cost a request per merge request, only get them when used
+						withApprovals := strings.Contains(r.format, ".Approvals") || strings.Contains(c.String("columns"), "approvals")
+						// Lists lack pipelines, those of each project cost a request per page
+						withPipelines := strings.Contains(r.format, "Pipeline") || strings.Contains(c.String("columns"), "pipeline") ||
+							r.output == OUTPUT_TABLE && c.String("columns") == ""
+						server := needGitlab(c)
+						server.token = needToken(c)
+						projectId := needRemoteUrl(c).path
+
+						stopPager := startPager(c)
+						count := 0
+						err = needMergeRequestPages(c, sweep, func(page []mergeRequest) error {
+							if withApprovals {
+								err := server.loadApprovals(projectId, page)
+								if nil != err {
+									return err
+								}
+							}
+							if withPipelines {
+								err := server.loadPipelines(projectId, page)
+								if nil != err {
+									return err
+								}
+							}
 
-						tmpl, err := newTemplate("default-merge-request", format, doColors(os.Stdout))
+							for _, request := range page {
+								err := r.render(request)
+								if err != nil {
+									return err
+								}
+							}
+							count += len(page)
+							return nil
+						})
 						if nil != err {
-							log.Fatal(err)
+							fatalPaging(err)
 						}
 
-						for _, request := range mergeRequests {
-							err = tmpl.Execute(os.Stdout, request)
-							if err != nil {
-								log.Fatal(err)
-							}
+						err = r.flush()
+						if nil != err {
+							fatalPaging(err)
 						}
+						stopPager()
 
-						countTmpl, err := newTemplate("count", "{{ .count | red | bold }} {{ \"merge requests\" | blue }}\n", true)
-						err = countTmpl.Execute(os.Stderr, map[string]string{
-							"count": strconv.Itoa(len(mergeRequests)),
-						})
+						err = r.printCount(count, "merge requests")
 						if nil != err {
 							log.Fatal(err)
 						}
@@ -553,17 +1174,35 @@ func main() {
 				{
 					Name:      "checkout",
 					ShortName: "co",
-					Usage:     "Checkout branch from merge request",
-					Flags:     mergeRequestFlags,
+					Usage:     "Checkout merge request by ID, or pick one, as a local tracking branch",
+					Flags: extendFlags(mergeRequestFlags,
+						cli.StringFlag{
+							Name:  "branch, b",
+							Usage: "Local branch name, default: the source branch",
+						},
+						cli.StringFlag{
+							Name:  "worktree",
+							Usage: "Check out into a new worktree at this path",
+						},
+						cli.BoolFlag{
+							Name:  "detach",
+							Usage: "Check out a detached HEAD instead of a branch",
+						},
+					),
 					Action: func(c *cli.Context) {
-						mergeRequest := promptForMergeRequest(c)
-						if mergeRequest == nil {
+						if c.Args().First() != "" {
+							createActionForMergeRequest(checkoutMergeRequest)(c)
+							return
+						}
+
+						request := promptForMergeRequest(c)
+						if nil == request {
 							return
 						}
-						fmt.Printf("Checkout out: \"%s\"...", mergeRequest.SourceBranch)
-						gitDir := needGitDir(c)
 
-						err := gitDir.checkout(mergeRequest.SourceBranch)
+						server := needGitlab(c)
+						server.token = needToken(c)
+						err := checkoutMergeRequest(c, server, needRemoteUrl(c).path, *request)
 						if nil != err {
 							log.Fatal(err)
 						}
@@ -571,6 +1210,295 @@ func main() {
 				},
 			},
 		},
+		{
+			Name:  "ci",
+			Usage: "Pipelines: status, list, watch, trace, retry, run, artifacts, ...",
+			Subcommands: []cli.Command{
+				{
+					Name:  "status",
+					Usage: "Show latest pipeline of the current branch, or of [ref], or by [id], with its jobs",
+					Flags: extendFlags(flags,
+						cli.BoolFlag{
+							Name:  "web, w",
+							Usage: "Open the pipeline in the browser",
+						},
+					),
+					Action: showPipelineStatus,
+				},
+				{
+					Name:      "list",
+					ShortName: "l",
+					Usage:     "List pipelines",
+					Flags: extendFlags(flags, extendFlags(pipelineQueryFlags,
+						cli.IntFlag{
+							Name:  "limit",
+							Value: 20,
+							Usage: "Maximum number of pipelines, 0 for all",
+						},
+					)...),
+					Action: listPipelines,
+				},
+				{
+					Name:  "watch",
+					Usage: "Watch latest pipeline of the current branch, or of [ref], or by [id], until it finishes",
+					Description: "Exits with 0 when the pipeline succeeds, 1 when it fails, 2 when canceled or skipped,\n" +
+						"   3 when waiting for manual jobs and 4 when it cannot be watched, eg. on errors of the server",
+					Flags: extendFlags(flags,
+						cli.IntFlag{
+							Name:  "interval",
+							Value: 3,
+							Usage: "Seconds between updates, backing off to 30 while nothing changes",
+						},
+						cli.IntFlag{
+							Name:  "wait",
+							Value: 60,
+							Usage: "Seconds to wait for a pipeline of HEAD of the current branch, eg. after pushing",
+						},
+						cli.BoolFlag{
+							Name:  "no-bell",
+							Usage: "Do not ring the terminal bell when the pipeline finishes",
+						},
+					),
+					Action: watchPipeline,
+				},
+				{
+					Name:  "trace",
+					Usage: "Show log of a job by [id], or by [name] or picked from the latest pipeline of the current branch, following it while it runs",
+					Flags: extendFlags(flags,
+						cli.IntFlag{
+							Name:  "pipeline, p",
+							Usage: "Pick the job from this pipeline ID",
+						},
+						cli.StringFlag{
+							Name:  "save",
+							Usage: "Save the full raw log to this file",
+						},
+						cli.IntFlag{
+							Name:  "interval",
+							Value: 3,
+							Usage: "Seconds between polls for more of the log",
+						},
+						cli.BoolFlag{
+							Name:  "no-follow",
+							Usage: "Show the log so far and stop, even if the job is still running",
+						},
+					),
+					Action: traceJob,
+				},
+				{
+					Name:  "retry",
+					Usage: "Retry failed jobs of the latest pipeline of the current branch, or of [ref], or by [id]",
+					Flags: extendFlags(flags,
+						cli.StringFlag{
+							Name:  "job, j",
+							Usage: "Retry only this job of the pipeline, by ID or name",
+						},
+					),
+					Action: createActionForPipeline("Retried", gitlab.retryPipeline, gitlab.retryJob),
+				},
+				{
+					Name:  "cancel",
+					Usage: "Cancel the latest pipeline of the current branch, or of [ref], or by [id]",
+					Flags: extendFlags(flags,
+						cli.StringFlag{
+							Name:  "job, j",
+							Usage: "Cancel only this job of the pipeline, by ID or name",
+						},
+					),
+					Action: createActionForPipeline("Canceled", gitlab.cancelPipeline, gitlab.cancelJob),
+				},
+				{
+					Name:  "run",
+					Usage: "Run a new pipeline for the current branch, or for [ref]",
+					Flags: extendFlags(flags,
+						cli.StringSliceFlag{
+							Name:  "var",
+							Usage: "Variable of the pipeline as KEY=VALUE, may be repeated",
+						},
+						cli.BoolFlag{
+							Name:  "web, w",
+							Usage: "Open the pipeline in the browser",
+						},
+					),
+					Action: runPipeline,
+				},
+				{
+					Name:  "play",
+					Usage: "Start a manual job by [id], or by [name] or picked from the latest pipeline of the current branch",
+					Flags: extendFlags(flags,
+						cli.IntFlag{
+							Name:  "pipeline, p",
+							Usage: "Pick the job from this pipeline ID",
+						},
+						cli.StringSliceFlag{
+							Name:  "var",
+							Usage: "Variable of the job as KEY=VALUE, may be repeated",
+						},
+					),
+					Action: playJob,
+				},
+				{
+					Name:  "artifacts",
+					Usage: "Download artifacts of a job by [id], or by [name] or picked from the latest pipeline of the current branch",
+					Flags: extendFlags(flags,
+						cli.IntFlag{
+							Name:  "pipeline, p",
+							Usage: "Pick the job from this pipeline ID",
+						},
+						cli.StringFlag{
+							Name:  "ref, r",
+							Usage: "Artifacts of the latest successful job of [name] for this branch or tag",
+						},
+						cli.StringFlag{
+							Name:  "path",
+							Usage: "Download only this file of the artifacts",
+						},
+						cli.StringFlag{
+							Name:  "save",
+							Usage: "Save to this file, or - for stdout, default: artifacts.zip, or the name of the file of --path",
+						},
+						cli.BoolFlag{
+							Name:  "continue, c",
+							Usage: "Continue an interrupted download of the same artifacts, if unchanged on the server",
+						},
+						cli.StringFlag{
+							Name:  "extract, x",
+							Usage: "Extract the archive into this directory",
+						},
+					),
+					Action: downloadArtifacts,
+				},
+			},
+		},
+		{
+			Name:  "issue",
+			Usage: "Issues: list, view, create, update, close, comment, ...",
+			Subcommands: []cli.Command{
+				{
+					Name:      "list",
+					ShortName: "l",
+					Usage:     "List issues",
+					Flags: extendFlags(flags, extendFlags(issueQueryFlags,
+						cli.StringFlag{
+							Name:  "state",
+							Value: "opened",
+							Usage: "State: opened, closed or all",
+						},
+						cli.IntFlag{
+							Name:  "limit",
+							Usage: "Maximum number of issues, default: all",
+						},
+					)...),
+					Action: listIssues,
+				},
+				{
+					Name:  "view",
+					Usage: "Show issue by ID, or of the current branch, in detail.",
+					Flags: extendFlags(flags,
+						cli.BoolFlag{
+							Name:  "web, w",
+							Usage: "Open in the browser, like browse",
+						},
+						cli.IntFlag{
+							Name:  "activity",
+							Value: 5,
+							Usage: "Number of recent notes to show, 0 for none",
+						},
+					),
+					Action: createActionForIssue(viewIssue),
+				},
+				{
+					Name:      "create",
+					ShortName: "c",
+					Usage:     "Create issue: [title], write title and description in $EDITOR without one",
+					Flags: extendFlags(flags, extendFlags(issueEditFlags,
+						cli.BoolFlag{
+							Name:  "web, w",
+							Usage: "Open the created issue in the browser",
+						},
+					)...),
+					Action: createIssue,
+				},
+				{
+					Name:  "start",
+					Usage: "Start work on issue by ID, on a new branch named after it",
+					Flags: extendFlags(flags,
+						cli.StringFlag{
+							Name:  "branch, b",
+							Usage: "Branch name, default: issue_branch template of ~/.labrc or .lab, or " + IssueBranchTemplate,
+						},
+						cli.StringFlag{
+							Name:  "base",
+							Usage: "Start point of the branch, default: the default branch of the remote",
+						},
+					),
+					Action: createActionForIssue(startIssue),
+				},
+				{
+					Name:  "update",
+					Usage: "Update issue by ID, or of the current branch.",
+					Flags: extendFlags(flags, extendFlags(issueEditFlags,
+						cli.StringFlag{
+							Name:  "title, t",
+							Usage: "New title",
+						},
+						cli.BoolFlag{
+							Name:  "edit, e",
+							Usage: "Edit title and description in $EDITOR",
+						},
+						cli.StringSliceFlag{
+							Name:  "add-label",
+							Usage: "Add label, repeat for several",
+						},
+						cli.StringSliceFlag{
+							Name:  "remove-label",
+							Usage: "Remove label, repeat for several",
+						},
+					)...),
+					Action: createActionForIssue(updateIssue),
+				},
+				{
+					Name:  "close",
+					Usage: "Close issue by ID, or of the current branch.",
+					Flags: extendFlags(flags,
+						cli.StringFlag{
+							Name:  "message, m",
+							Usage: "Comment to add before closing",
+						},
+					),
+					Action: createActionForIssue(changeIssueState(ISSUE_STATE_EVENT_CLOSE)),
+				},
+				{
+					Name:  "reopen",
+					Usage: "Reopen issue by ID, or of the current branch.",
+					Flags: extendFlags(flags,
+						cli.StringFlag{
+							Name:  "message, m",
+							Usage: "Comment to add before reopening",
+						},
+					),
+					Action: createActionForIssue(changeIssueState(ISSUE_STATE_EVENT_REOPEN)),
+				},
+				{
+					Name:  "comment",
+					Usage: "Comment on issue by ID, or of the current branch: [id] [message], from stdin or $EDITOR",
+					Flags: extendFlags(flags,
+						cli.StringFlag{
+							Name:  "message, m",
+							Usage: "Comment text, use - to read from stdin",
+						},
+					),
+					Action: createActionForIssue(commentOnIssue),
+				},
+				{
+					Name:      "browse",
+					ShortName: "b",
+					Usage:     "Browse issue by ID, of the current branch, or the issues of the project.",
+					Flags:     flags,
+					Action:    browseIssue,
+				},
+			},
+		},
 	}
 
 	app.Run(os.Args)
@@ -0,0 +1,389 @@
+package main
+
+import (
+	"github.com/mattn/go-runewidth"
+	"html"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+var markdownFence = regexp.MustCompile("^\\s*(```+|~~~+)\\s*([\\w+-]*)")
+var markdownHeading = regexp.MustCompile(`^\s{0,3}(#{1,6})\s+(.*?)\s*#*\s*$`)
+var markdownRule = regexp.MustCompile(`^\s{0,3}([-*_])(\s*[-*_]){2,}\s*$`)
+var markdownQuote = regexp.MustCompile(`^\s{0,3}>\s?(.*)$`)
+var markdownListItem = regexp.MustCompile(`^(\s*)([-*+]|\d+[.)])\s+(.*)$`)
+var markdownTask = regexp.MustCompile(`^\[([ xX])\]\s+`)
+var markdownTableSeparator = regexp.MustCompile(`^\s*:?-+:?\s*$`)
+
+var markdownCode = regexp.MustCompile("`([^`]+)`")
+var markdownImage = regexp.MustCompile(`!\[([^\]]*)\]\(([^)\s]+)(?:\s+"[^"]*")?\)`)
+var markdownLink = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)(?:\s+"[^"]*")?\)`)
+var markdownAutolink = regexp.MustCompile(`<(https?://[^>\s]+)>`)
+var markdownBreak = regexp.MustCompile(`(?i)<br\s*/?>`)
+var markdownHtmlComment = regexp.MustCompile(`<!--.*?-->`)
+var markdownHtmlTag = regexp.MustCompile(`</?[a-zA-Z][\w-]*(\s[^>]*)?/?>`)
+var markdownBold = regexp.MustCompile(`\*\*([^*]+)\*\*|__([^_]+)__`)
+var markdownItalic = regexp.MustCompile(`(^|[^\w*])\*([^*\s][^*]*)\*|(^|[^\w])_([^_\s][^_]*)_($|[^\w])`)
+var markdownStrike = regexp.MustCompile(`~~([^~]+)~~`)
+var markdownReference = regexp.MustCompile(`(^|[^\w/&])((?:[\w.-]+/)+[\w.-]+)?([!#])(\d+)\b`)
+var markdownPlaceholder = regexp.MustCompile("\x00(\\d+)\x00")
+
+var codeToken = regexp.MustCompile(`"(?:[^"\\]|\\.)*"|'(?:[^'\\]|\\.)*'|//.*$|\b(?:break|case|class|const|continue|def|default|defer|elif|else|end|except|false|for|from|func|function|go|if|import|in|let|nil|None|null|package|return|self|struct|switch|this|True|true|False|try|type|var|while)\b`)
+var hashComment = regexp.MustCompile(`(^|\s)#.*$`)
+var hashCommentLanguages = map[string]bool{
+	"sh": true, "bash": true, "shell": true, "console": true, "zsh": true,
+	"python": true, "py": true, "ruby": true, "rb": true, "perl": true,
+	"yaml": true, "yml": true, "toml": true, "dockerfile": true, "make": true, "makefile": true,
+}
+
+var ansiSequence = regexp.MustCompile("\x1b\\[[0-9;]*m|\x1b\\]8;;[^\x1b]*\x1b\\\\")
+
+/// Renders GitLab flavored markdown for the terminal, references like !123 and #45 link into projectUrl
+type markdownRenderer struct {
+	funcs      template.FuncMap
+	colors     bool
+	projectUrl string
+}
+
+/// Render markdown for the terminal, webUrl of the project, or one of its merge requests or issues, is used for references
+func renderMarkdown(input string, colors bool, webUrl ...string) string {
+	m := markdownRenderer{
+		funcs:  templateFuncs[colors],
+		colors: colors,
+	}
+	if len(webUrl) > 0 {
+		m.projectUrl = projectWebUrl(webUrl[0])
+	}
+	return m.render(input)
+}
+
+/// Get project url of an url of the project, or one of its merge requests or issues
+func projectWebUrl(webUrl string) string {
+	for _, sep := range []string{"/-/", "/merge_requests/", "/issues/"} {
+		if i := strings.Index(webUrl, sep); i >= 0 {
+			return webUrl[:i]
+		}
+	}
+	return strings.TrimSuffix(webUrl, "/")
+}
+
+/// Width of s as shown on the terminal, without colors and hyperlinks
+func visibleWidth(s string) int {
+	return runewidth.StringWidth(ansiSequence.ReplaceAllString(s, ""))
+}
+
+func (m markdownRenderer) style(name, input string) string {
+	return m.funcs[name].(func(string) string)(input)
+}
+
+func (m markdownRenderer) render(input string) string {
+	input = markdownHtmlComment.ReplaceAllString(strings.Replace(input, "\r\n", "\n", -1), "")
+
+	var out []string
+	var table [][]string
+	var fence, lang string
+	inComment := false
+
+	for _, line := range strings.Split(input, "\n") {
+		if fence != "" {
+			if strings.HasPrefix(strings.TrimSpace(line), fence) {
+				fence = ""
+				continue
+			}
+			out = append(out, "    "+m.highlight(line, lang))
+			continue
+		}
+
+		// Multi line html comments
+		commented := inComment
+		if inComment {
+			if i := strings.Index(line, "-->"); i >= 0 {
+				line = line[i+3:]
+				inComment = false
+			} else {
+				continue
+			}
+		}
+		if i := strings.Index(line, "<!--"); i >= 0 {
+			line = line[:i]
+			inComment = true
+			commented = true
+		}
+
+		trimmed := strings.TrimSpace(line)
+		if commented && trimmed == "" {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "|") {
+			table = append(table, splitTableRow(trimmed))
+			continue
+		}
+		if nil != table {
+			out = append(out, m.table(table)...)
+			table = nil
+		}
+
+		if match := markdownFence.FindStringSubmatch(line); nil != match {
+			fence, lang = match[1], strings.ToLower(match[2])
+			continue
+		}
+		if match := markdownHeading.FindStringSubmatch(line); nil != match {
+			heading := m.style("bold", m.inline(match[2]))
+			if len(match[1]) == 1 {
+				heading = m.style("underline", heading)
+			}
+			out = append(out, heading)
+			continue
+		}
+		if markdownRule.MatchString(line) {
+			out = append(out, m.style("faint", strings.Repeat("─", 40)))
+			continue
+		}
+		if match := markdownQuote.FindStringSubmatch(line); nil != match {
+			out = append(out, m.style("faint", "│ ")+m.inline(match[1]))
+			continue
+		}
+		if match := markdownListItem.FindStringSubmatch(line); nil != match {
+			out = append(out, m.listItem(match[1], match[2], match[3]))
+			continue
+		}
+		if trimmed != "" && markdownHtmlTag.ReplaceAllString(trimmed, "") == "" && !markdownBreak.MatchString(trimmed) {
+			// Lines of html only, eg. <details>
+			continue
+		}
+
+		out = append(out, m.inline(line))
+	}
+	if nil != table {
+		out = append(out, m.table(table)...)
+	}
+
+	return strings.Join(out, "\n")
+}
+
+func (m markdownRenderer) listItem(indent, marker, text string) string {
+	bullet := m.style("yellow", marker)
+	if marker == "-" || marker == "*" || marker == "+" {
+		bullet = m.style("yellow", "•")
+	}
+
+	if match := markdownTask.FindStringSubmatch(text); nil != match {
+		text = text[len(match[0]):]
+		if match[1] == " " {
+			bullet = "☐"
+		} else {
+			bullet = m.style("green", "☑")
+			text = m.style("faint", m.inline(text))
+			return indent + bullet + " " + text
+		}
+	}
+
+	return indent + bullet + " " + m.inline(text)
+}
+
+func splitTableRow(row string) []string {
+	row = strings.TrimSuffix(strings.TrimPrefix(row, "|"), "|")
+	cells := strings.Split(row, "|")
+	for i, cell := range cells {
+		cells[i] = strings.TrimSpace(cell)
+	}
+	return cells
+}
+
+/// Render table rows with aligned columns, the first row as header
+func (m markdownRenderer) table(rows [][]string) []string {
+	var rendered [][]string
+	var widths []int
+	for _, row := range rows {
+		separator := true
+		for _, cell := range row {
+			separator = separator && markdownTableSeparator.MatchString(cell)
+		}
+		if separator {
+			continue
+		}
+
+		cells := make([]string, len(row))
+		for i, cell := range row {
+			cells[i] = m.inline(cell)
+			if i >= len(widths) {
+				widths = append(widths, 0)
+			}
+			if w := visibleWidth(cells[i]); w > widths[i] {
+				widths[i] = w
+			}
+		}
+		rendered = append(rendered, cells)
+	}
+
+	var lines []string
+	for r, cells := range rendered {
+		var line string
+		for i, cell := range cells {
+			if i > 0 {
+				line += "  "
+			}
+			if r == 0 {
+				cell = m.style("bold", cell)
+			}
+			if i < len(cells)-1 {
+				cell += strings.Repeat(" ", widths[i]-visibleWidth(cell))
+			}
+			line += cell
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+/// Render inline markup: code spans, links, emphasis, html and references
+func (m markdownRenderer) inline(text string) string {
+	// Rendered code and links are kept aside, so emphasis and references are not looked for inside them
+	var kept []string
+	keep := func(rendered string) string {
+		kept = append(kept, rendered)
+		return "\x00" + strconv.Itoa(len(kept)-1) + "\x00"
+	}
+
+	text = markdownCode.ReplaceAllStringFunc(text, func(code string) string {
+		return keep(m.style("cyan", strings.Trim(code, "`")))
+	})
+	text = markdownImage.ReplaceAllStringFunc(text, func(image string) string {
+		match := markdownImage.FindStringSubmatch(image)
+		alt := match[1]
+		if alt == "" {
+			alt = "image"
+		}
+		return keep(m.link(m.absoluteUrl(match[2]), "["+alt+"]"))
+	})
+	text = markdownLink.ReplaceAllStringFunc(text, func(link string) string {
+		match := markdownLink.FindStringSubmatch(link)
+		return keep(m.link(m.absoluteUrl(match[2]), match[1]))
+	})
+	text = markdownAutolink.ReplaceAllStringFunc(text, func(link string) string {
+		url := strings.Trim(link, "<>")
+		return keep(m.link(url, url))
+	})
+
+	text = markdownBreak.ReplaceAllString(text, "")
+	text = markdownHtmlTag.ReplaceAllString(text, "")
+	text = html.UnescapeString(text)
+
+	text = markdownReference.ReplaceAllStringFunc(text, func(ref string) string {
+		match := markdownReference.FindStringSubmatch(ref)
+		return match[1] + keep(m.reference(match[2], match[3], match[4]))
+	})
+
+	text = markdownBold.ReplaceAllStringFunc(text, func(bold string) string {
+		return m.style("bold", bold[2:len(bold)-2])
+	})
+	text = markdownItalic.ReplaceAllStringFunc(text, func(italic string) string {
+		match := markdownItalic.FindStringSubmatch(italic)
+		if match[2] != "" {
+			return match[1] + m.style("italic", match[2])
+		}
+		return match[3] + m.style("italic", match[4]) + match[5]
+	})
+	text = markdownStrike.ReplaceAllStringFunc(text, func(strike string) string {
+		return m.style("faint", strike[2:len(strike)-2])
+	})
+
+	return markdownPlaceholder.ReplaceAllStringFunc(text, func(placeholder string) string {
+		i, _ := strconv.Atoi(strings.Trim(placeholder, "\x00"))
+		return kept[i]
+	})
+}
+
+/// Link as terminal hyperlink, or as "text (url)" without colors
+func (m markdownRenderer) link(url, text string) string {
+	if m.colors {
+		return hyperlink(url, m.style("blue", text))
+	}
+	if url == text || url == "" {
+		return text
+	}
+	return text + " (" + url + ")"
+}
+
+/// Make links relative to the project, eg. uploads, absolute
+func (m markdownRenderer) absoluteUrl(link string) string {
+	if m.projectUrl == "" || strings.Contains(link, "://") || strings.HasPrefix(link, "#") {
+		return link
+	}
+	if strings.HasPrefix(link, "/") {
+		base, err := url.Parse(m.projectUrl)
+		if nil != err {
+			return link
+		}
+		return base.Scheme + "://" + base.Host + link
+	}
+	return m.projectUrl + "/" + link
+}
+
+/// Link !123 to merge requests and #45 to issues, of the project or the given one, eg. group/project!123
+func (m markdownRenderer) reference(project, kind, id string) string {
+	text := project + kind + id
+	if !m.colors {
+		return text
+	}
+
+	projectUrl := m.projectUrl
+	if project != "" && projectUrl != "" {
+		base, err := url.Parse(projectUrl)
+		if nil == err {
+			projectUrl = base.Scheme + "://" + base.Host + "/" + project
+		}
+	}
+	if projectUrl == "" {
+		return m.style("yellow", text)
+	}
+
+	path := "/-/issues/"
+	if kind == "!" {
+		path = "/-/merge_requests/"
+	}
+	return hyperlink(projectUrl+path+id, m.style("yellow", text))
+}
+
+/// Highlight a line of a code block: strings, comments and common keywords, diffs by added and removed lines
+func (m markdownRenderer) highlight(line, lang string) string {
+	if !m.colors {
+		return line
+	}
+
+	if lang == "diff" || lang == "patch" {
+		switch {
+		case strings.HasPrefix(line, "+"):
+			return m.style("green", line)
+		case strings.HasPrefix(line, "-"):
+			return m.style("red", line)
+		case strings.HasPrefix(line, "@@"):
+			return m.style("cyan", line)
+		}
+		return line
+	}
+
+	var comment string
+	if hashCommentLanguages[lang] {
+		if loc := hashComment.FindStringIndex(line); nil != loc {
+			line, comment = line[:loc[0]], line[loc[0]:]
+		}
+	}
+
+	line = codeToken.ReplaceAllStringFunc(line, func(token string) string {
+		switch {
+		case strings.HasPrefix(token, "//"):
+			return m.style("blue", token)
+		case strings.HasPrefix(token, "\""), strings.HasPrefix(token, "'"):
+			return m.style("green", token)
+		}
+		return m.style("magenta", token)
+	})
+	if comment != "" {
+		line += m.style("blue", comment)
+	}
+	return line
+}
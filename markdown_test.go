@@ -0,0 +1,83 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderMarkdownBlocks(t *testing.T) {
+	input := strings.Join([]string{
+		"# Release",
+		"<details><summary>More</summary>",
+		"- [ ] Update changelog",
+		"- [x] Tag **release**",
+		"1. First",
+		"> Quoted &amp; escaped",
+		"",
+		"| Name | Size |",
+		"|------|-----:|",
+		"| lab | 12 |",
+		"| gitlab-ce | 3 |",
+		"",
+		"```go",
+		"func main() {}",
+		"```",
+		"<!-- hidden",
+		"comment -->",
+		"Done<br>",
+		"</details>",
+	}, "\n")
+
+	expected := strings.Join([]string{
+		"Release",
+		"More",
+		"☐ Update changelog",
+		"☑ Tag release",
+		"1. First",
+		"│ Quoted & escaped",
+		"",
+		"Name       Size",
+		"lab        12",
+		"gitlab-ce  3",
+		"",
+		"    func main() {}",
+		"Done",
+	}, "\n")
+
+	if got := renderMarkdown(input, false); got != expected {
+		t.Fatalf("Expected:\n%s\nGot:\n%s\n", expected, got)
+	}
+}
+
+func TestRenderMarkdownInline(t *testing.T) {
+	got := renderMarkdown("Fixes #45 and group/other!7, see `snake_case_name` and ![screenshot](/uploads/a.png)", false, "https://gitlab.example.com/group/lab/-/merge_requests/3")
+	if got != "Fixes #45 and group/other!7, see snake_case_name and [screenshot] (https://gitlab.example.com/uploads/a.png)" {
+		t.Fatal("Unexpected markdown:", got)
+	}
+}
+
+func TestRenderMarkdownReferenceLinks(t *testing.T) {
+	got := renderMarkdown("Fixes #45, after !12 and group/other!7", true, "https://gitlab.example.com/group/lab/-/merge_requests/3")
+	for _, url := range []string{
+		"https://gitlab.example.com/group/lab/-/issues/45",
+		"https://gitlab.example.com/group/lab/-/merge_requests/12",
+		"https://gitlab.example.com/group/other/-/merge_requests/7",
+	} {
+		if !strings.Contains(got, "\x1b]8;;"+url+"\x1b\\") {
+			t.Fatalf("Expected hyperlink to %s in: %q\n", url, got)
+		}
+	}
+}
+
+func TestProjectWebUrl(t *testing.T) {
+	for webUrl, expected := range map[string]string{
+		"https://gitlab.example.com/group/lab/-/merge_requests/3": "https://gitlab.example.com/group/lab",
+		"https://gitlab.example.com/group/lab/merge_requests/3":   "https://gitlab.example.com/group/lab",
+		"https://gitlab.example.com/group/lab/issues/45":          "https://gitlab.example.com/group/lab",
+		"https://gitlab.example.com/group/lab/":                   "https://gitlab.example.com/group/lab",
+	} {
+		if got := projectWebUrl(webUrl); got != expected {
+			t.Fatalf("Expected project url of %s to be %s, got: %s\n", webUrl, expected, got)
+		}
+	}
+}
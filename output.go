@@ -0,0 +1,429 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/codegangsta/cli"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+const OUTPUT_TEMPLATE string = "template"
+const OUTPUT_JSON string = "json"
+const OUTPUT_YAML string = "yaml"
+const OUTPUT_CSV string = "csv"
+const OUTPUT_TSV string = "tsv"
+const OUTPUT_TABLE string = "table"
+
+/// Default csv columns for merge requests
+var mergeRequestColumns = []string{"iid", "title", "state", "author.username", "source_branch", "target_branch", "web_url"}
+
+/// Default csv columns for issues
+var issueColumns = []string{"iid", "title", "state", "author.username", "web_url"}
+
+/// Default csv columns for pipelines
+var pipelineColumns = []string{"id", "status", "ref", "sha", "web_url"}
+
+/// Default csv columns for discussions
+var discussionColumns = []string{"id", "notes.author.username", "notes.body"}
+
+/// Renders items as template, table, json, yaml, csv or tsv, field names are those of the api
+type renderer struct {
+	output  string
+	table   *table
+	stream  bool // list of items: json as NDJSON, yaml as documents, one header for csv
+	tmpl    *template.Template
+	format  string
+	columns []string
+	header  bool
+	out     io.Writer
+	csv     *csv.Writer
+}
+
+/// Get renderer for --output, using --format, a named "@format" or defaultFormat for templates and --columns or defaultColumns for csv
+func newRenderer(c *cli.Context, name, defaultFormat string, defaultColumns []string, stream bool) (*renderer, error) {
+	r := &renderer{
+		output: c.String("output"),
+		stream: stream,
+		out:    currentStdout{},
+	}
+
+	switch r.output {
+	case "", OUTPUT_TEMPLATE:
+		r.output = OUTPUT_TEMPLATE
+		format, err := resolveFormat(c, defaultFormat)
+		if nil != err {
+			return nil, err
+		}
+		tmpl, err := newTemplate(name, format, doColors(os.Stdout))
+		if nil != err {
+			return nil, err
+		}
+		r.tmpl = tmpl
+		r.format = format
+	case OUTPUT_JSON, OUTPUT_YAML:
+	case OUTPUT_TABLE:
+		r.table = &table{
+			columns: defaultColumns,
+			colors:  doColors(os.Stdout),
+			width:   terminalWidth(os.Stdout),
+		}
+		if columns := c.String("columns"); columns != "" {
+			r.table.columns = strings.Split(columns, ",")
+		}
+	case OUTPUT_CSV, OUTPUT_TSV:
+		r.columns = defaultColumns
+		if columns := c.String("columns"); columns != "" {
+			r.columns = strings.Split(columns, ",")
+		}
+		r.csv = csv.NewWriter(r.out)
+		if r.output == OUTPUT_TSV {
+			r.csv.Comma = '\t'
+		}
+	default:
+		return nil, fmt.Errorf("Unknown output: %s, use one of: template, table, json, yaml, csv, tsv\n", r.output)
+	}
+
+	return r, nil
+}
+
+/// Use named table columns, and render lists as a table by default on terminals
+func (r *renderer) withTable(c *cli.Context, columns map[string]tableColumn, defaultColumns []string) {
+	if c.String("output") == "" && c.String("format") == "" && doColors(os.Stdout) {
+		r.output = OUTPUT_TABLE
+		r.table = &table{
+			colors: true,
+			width:  terminalWidth(os.Stdout),
+		}
+	}
+	if r.output != OUTPUT_TABLE {
+		return
+	}
+
+	r.table.defs = columns
+	r.table.columns = defaultColumns
+	if columns := c.String("columns"); columns != "" {
+		r.table.columns = strings.Split(columns, ",")
+	}
+}
+
+func (r *renderer) isTemplate() bool {
+	return r.output == OUTPUT_TEMPLATE
+}
+
+func (r *renderer) render(item interface{}) error {
+	if r.isTemplate() {
+		return r.tmpl.Execute(r.out, item)
+	}
+	if r.output == OUTPUT_TABLE {
+		return r.table.add(item)
+	}
+
+	data, err := json.Marshal(item)
+	if nil != err {
+		return err
+	}
+
+	switch r.output {
+	case OUTPUT_JSON:
+		if r.stream {
+			_, err = fmt.Fprintf(r.out, "%s\n", data)
+			return err
+		}
+		var indented bytes.Buffer
+		err = json.Indent(&indented, data, "", "  ")
+		if nil != err {
+			return err
+		}
+		_, err = fmt.Fprintf(r.out, "%s\n", indented.Bytes())
+		return err
+	case OUTPUT_YAML:
+		value, err := decodeOrderedJson(data)
+		if nil != err {
+			return err
+		}
+		if r.stream {
+			_, err = io.WriteString(r.out, "---\n")
+			if nil != err {
+				return err
+			}
+		}
+		return writeYaml(r.out, value, 0)
+	}
+
+	value, err := decodeOrderedJson(data)
+	if nil != err {
+		return err
+	}
+	if !r.header {
+		if nil == r.columns {
+			r.columns = scalarKeys(value)
+		}
+		err = r.csv.Write(r.columns)
+		if nil != err {
+			return err
+		}
+		r.header = true
+	}
+
+	record := make([]string, len(r.columns))
+	for i, column := range r.columns {
+		record[i] = lookupColumn(value, strings.Split(column, "."))
+	}
+	err = r.csv.Write(record)
+	if nil != err {
+		return err
+	}
+	r.csv.Flush()
+	return r.csv.Error()
+}
+
+/// Finish output, eg. writing the table or flushing buffered csv
+func (r *renderer) flush() error {
+	if r.output == OUTPUT_TABLE {
+		return r.table.write(r.out)
+	}
+	if nil != r.csv {
+		r.csv.Flush()
+		return r.csv.Error()
+	}
+	return nil
+}
+
+/// Print the number of items listed to stderr after templates and tables, eg. "3 issues"
+func (r *renderer) printCount(count int, items string) error {
+	if !r.isTemplate() && r.output != OUTPUT_TABLE {
+		return nil
+	}
+
+	tmpl, err := newTemplate("count", "{{ .count | red | bold }} {{ .items | blue }}\n", true)
+	if nil != err {
+		return err
+	}
+	return tmpl.Execute(os.Stderr, map[string]string{
+		"count": strconv.Itoa(count),
+		"items": items,
+	})
+}
+
+/// Get item as decoded json, keeping the order of fields
+func decodeItem(item interface{}) (interface{}, error) {
+	data, err := json.Marshal(item)
+	if nil != err {
+		return nil, err
+	}
+	return decodeOrderedJson(data)
+}
+
+/// JSON object keeping the order of its keys
+type orderedObject struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+/// Decode json into *orderedObject, []interface{}, json.Number, string, bool or nil
+func decodeOrderedJson(data []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return decodeOrderedValue(dec)
+}
+
+func decodeOrderedValue(dec *json.Decoder) (interface{}, error) {
+	token, err := dec.Token()
+	if nil != err {
+		return nil, err
+	}
+
+	switch token {
+	case json.Delim('{'):
+		object := &orderedObject{values: make(map[string]interface{})}
+		for dec.More() {
+			key, err := dec.Token()
+			if nil != err {
+				return nil, err
+			}
+			value, err := decodeOrderedValue(dec)
+			if nil != err {
+				return nil, err
+			}
+			object.keys = append(object.keys, key.(string))
+			object.values[key.(string)] = value
+		}
+		_, err = dec.Token()
+		return object, err
+	case json.Delim('['):
+		list := []interface{}{}
+		for dec.More() {
+			value, err := decodeOrderedValue(dec)
+			if nil != err {
+				return nil, err
+			}
+			list = append(list, value)
+		}
+		_, err = dec.Token()
+		return list, err
+	}
+
+	return token, nil
+}
+
+/// Write decoded json as yaml, indented by the given number of spaces
+func writeYaml(w io.Writer, value interface{}, indent int) error {
+	prefix := strings.Repeat(" ", indent)
+
+	switch value := value.(type) {
+	case *orderedObject:
+		if len(value.keys) == 0 {
+			_, err := fmt.Fprintf(w, "%s{}\n", prefix)
+			return err
+		}
+		for _, key := range value.keys {
+			err := writeYamlEntry(w, prefix+yamlScalar(key)+":", value.values[key], indent)
+			if nil != err {
+				return err
+			}
+		}
+		return nil
+	case []interface{}:
+		if len(value) == 0 {
+			_, err := fmt.Fprintf(w, "%s[]\n", prefix)
+			return err
+		}
+		for _, item := range value {
+			err := writeYamlEntry(w, prefix+"-", item, indent)
+			if nil != err {
+				return err
+			}
+		}
+		return nil
+	}
+
+	_, err := fmt.Fprintf(w, "%s%s\n", prefix, yamlScalar(value))
+	return err
+}
+
+/// Write "key:" or "-" followed by a scalar on the same line, or a nested value on the following lines
+func writeYamlEntry(w io.Writer, lead string, value interface{}, indent int) error {
+	switch v := value.(type) {
+	case *orderedObject:
+		if len(v.keys) == 0 {
+			_, err := fmt.Fprintf(w, "%s {}\n", lead)
+			return err
+		}
+	case []interface{}:
+		if len(v) == 0 {
+			_, err := fmt.Fprintf(w, "%s []\n", lead)
+			return err
+		}
+	default:
+		_, err := fmt.Fprintf(w, "%s %s\n", lead, yamlScalar(value))
+		return err
+	}
+
+	if object, ok := value.(*orderedObject); ok && strings.HasSuffix(lead, "-") {
+		// First key on the same line as the dash: "- id: 1"
+		var nested bytes.Buffer
+		err := writeYaml(&nested, object, indent+2)
+		if nil != err {
+			return err
+		}
+		_, err = io.WriteString(w, lead+" "+strings.TrimLeft(nested.String(), " "))
+		return err
+	}
+
+	_, err := fmt.Fprintf(w, "%s\n", lead)
+	if nil != err {
+		return err
+	}
+	return writeYaml(w, value, indent+2)
+}
+
+/// Format scalar for yaml, quoting strings that would otherwise be misread
+func yamlScalar(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return strconv.FormatBool(value)
+	case json.Number:
+		return value.String()
+	case string:
+		if yamlNeedsQuotes(value) {
+			quoted, _ := json.Marshal(value)
+			return string(quoted)
+		}
+		return value
+	}
+
+	return fmt.Sprint(value)
+}
+
+func yamlNeedsQuotes(s string) bool {
+	if s == "" || strings.TrimSpace(s) != s {
+		return true
+	}
+	switch strings.ToLower(s) {
+	case "null", "~", "true", "false", "yes", "no", "on", "off":
+		return true
+	}
+	if _, err := strconv.ParseFloat(s, 64); nil == err {
+		return true
+	}
+	if strings.ContainsAny(s[:1], "-?:,[]{}#&*!|>'\"%@`") {
+		return true
+	}
+	return strings.ContainsAny(s, "\n\t\"\\") || strings.Contains(s, ": ") || strings.Contains(s, " #")
+}
+
+/// Keys of top level scalar values, default columns for csv
+func scalarKeys(value interface{}) []string {
+	object, ok := value.(*orderedObject)
+	if !ok {
+		return []string{}
+	}
+
+	var keys []string
+	for _, key := range object.keys {
+		switch object.values[key].(type) {
+		case *orderedObject, []interface{}:
+		default:
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+/// Get column value by path, eg. "author.username", values of lists are joined, eg. "assignees.username"
+func lookupColumn(value interface{}, path []string) string {
+	switch v := value.(type) {
+	case *orderedObject:
+		if len(path) == 0 {
+			data, _ := json.Marshal(v.values)
+			return string(data)
+		}
+		return lookupColumn(v.values[path[0]], path[1:])
+	case []interface{}:
+		var values []string
+		for _, item := range v {
+			values = append(values, lookupColumn(item, path))
+		}
+		return strings.Join(values, ",")
+	case nil:
+		return ""
+	case string:
+		if len(path) == 0 {
+			return v
+		}
+		return ""
+	}
+
+	if len(path) > 0 {
+		return ""
+	}
+	return yamlScalar(value)
+}
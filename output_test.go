@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+)
+
+type outputTestItem struct {
+	Iid       int      `json:"iid"`
+	Title     string   `json:"title"`
+	Author    *user    `json:"author"`
+	Labels    []string `json:"labels"`
+	Assignees []user   `json:"assignees"`
+}
+
+var outputTestItems = []outputTestItem{
+	{1, "Fix: the thing", &user{Id: 1, Username: "alice"}, []string{"bug"}, []user{{Username: "bob"}, {Username: "carol"}}},
+	{2, "yes", nil, []string{}, nil},
+}
+
+func TestRenderNdjson(t *testing.T) {
+	var out bytes.Buffer
+	r := &renderer{output: OUTPUT_JSON, stream: true, out: &out}
+	for _, item := range outputTestItems {
+		if err := r.render(item); nil != err {
+			t.Fatal(err)
+		}
+	}
+
+	expected := `{"iid":1,"title":"Fix: the thing","author":{"id":1,"username":"alice","name":""},"labels":["bug"],"assignees":[{"id":0,"username":"bob","name":""},{"id":0,"username":"carol","name":""}]}
+{"iid":2,"title":"yes","author":null,"labels":[],"assignees":null}
+`
+	if out.String() != expected {
+		t.Fatalf("Expected:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
+
+func TestRenderYaml(t *testing.T) {
+	var out bytes.Buffer
+	r := &renderer{output: OUTPUT_YAML, out: &out}
+	if err := r.render(outputTestItems[0]); nil != err {
+		t.Fatal(err)
+	}
+
+	expected := `iid: 1
+title: "Fix: the thing"
+author:
+  id: 1
+  username: alice
+  name: ""
+labels:
+  - bug
+assignees:
+  - id: 0
+    username: bob
+    name: ""
+  - id: 0
+    username: carol
+    name: ""
+`
+	if out.String() != expected {
+		t.Fatalf("Expected:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
+
+func TestRenderCsvColumns(t *testing.T) {
+	var out bytes.Buffer
+	r := &renderer{
+		output:  OUTPUT_CSV,
+		stream:  true,
+		out:     &out,
+		csv:     csv.NewWriter(&out),
+		columns: []string{"iid", "author.username", "assignees.username", "labels"},
+	}
+	for _, item := range outputTestItems {
+		if err := r.render(item); nil != err {
+			t.Fatal(err)
+		}
+	}
+	r.flush()
+
+	expected := "iid,author.username,assignees.username,labels\n1,alice,\"bob,carol\",bug\n2,,,\n"
+	if out.String() != expected {
+		t.Fatalf("Expected:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"github.com/andrew-d/go-termutil"
+	"github.com/codegangsta/cli"
+	"log"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+)
+
+/// Exit code when the pager was quit before all output was written, like git killed by SIGPIPE
+const PAGER_QUIT_EXIT_CODE int = 128 + int(syscall.SIGPIPE)
+
+/// Pipe to the pager standing in for stdout, and the terminal the pager writes to
+var pagerPipe, pagerTerminal *os.File
+
+/// Stop the running pager, if any
+var stopRunningPager = func() {}
+
+/// Writes to the current stdout, which is the pager pipe while paging
+type currentStdout struct{}
+
+func (currentStdout) Write(p []byte) (int, error) {
+	return os.Stdout.Write(p)
+}
+
+/// Get the terminal behind output, the pager's terminal when output is the pager pipe
+func underlyingTerminal(output *os.File) *os.File {
+	if nil != pagerPipe && output == pagerPipe {
+		return pagerTerminal
+	}
+	return output
+}
+
+/// Get pager from $LAB_PAGER, $PAGER or core.pager, default: less -FRX
+func getPager(c *cli.Context) string {
+	if pager := os.Getenv("LAB_PAGER"); pager != "" {
+		return pager
+	}
+	if pager := os.Getenv("PAGER"); pager != "" {
+		return pager
+	}
+	if pager, err := needGitDir(c).output("config", "core.pager"); nil == err && pager != "" {
+		return pager
+	}
+	return "less -FRX"
+}
+
+/// Send stdout through the pager when it is a terminal, unless --no-pager, call stop when done writing.
+/// Writes fail with EPIPE once the pager is quit, see fatalPaging
+func startPager(c *cli.Context) (stop func()) {
+	stop = func() {}
+	if c.Bool("no-pager") || nil != pagerPipe || !termutil.Isatty(os.Stdout.Fd()) {
+		return
+	}
+
+	pager := getPager(c)
+	if pager == "cat" {
+		return
+	}
+
+	return runPager(pager)
+}
+
+/// Run the pager command on a pipe standing in for stdout, call stop when done writing
+func runPager(pager string) (stop func()) {
+	stop = func() {}
+	r, w, err := os.Pipe()
+	if nil != err {
+		return
+	}
+
+	cmd := exec.Command("sh", "-c", pager)
+	cmd.Stdin = r
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = os.Environ()
+	// Keep colors and quit if output fits the screen, like git does
+	if os.Getenv("LESS") == "" {
+		cmd.Env = append(cmd.Env, "LESS=FRX")
+	}
+	if os.Getenv("LV") == "" {
+		cmd.Env = append(cmd.Env, "LV=-c")
+	}
+	err = cmd.Start()
+	r.Close()
+	if nil != err {
+		w.Close()
+		return
+	}
+
+	exited := make(chan struct{})
+	go func() {
+		cmd.Wait()
+		close(exited)
+	}()
+
+	pagerPipe, pagerTerminal = w, os.Stdout
+	os.Stdout = w
+
+	var once sync.Once
+	stop = func() {
+		once.Do(func() {
+			os.Stdout = pagerTerminal
+			pagerPipe, pagerTerminal = nil, nil
+			stopRunningPager = func() {}
+			w.Close()
+			<-exited
+		})
+	}
+	stopRunningPager = stop
+	return stop
+}
+
+/// Exit on an error, stopping the pager first, quietly if it was quit before all output was written
+func fatalPaging(err error) {
+	stopRunningPager()
+	if isBrokenPipe(err) {
+		os.Exit(PAGER_QUIT_EXIT_CODE)
+	}
+	log.Output(2, fmt.Sprint(err))
+	os.Exit(1)
+}
+
+/// Writing failed as the pager was quit, by a write of lab or a git command
+func isBrokenPipe(err error) bool {
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		status, ok := exitErr.Sys().(syscall.WaitStatus)
+		return ok && status.Signaled() && status.Signal() == syscall.SIGPIPE
+	}
+	if pathErr, ok := err.(*os.PathError); ok {
+		return pathErr.Err == syscall.EPIPE
+	}
+	return err == syscall.EPIPE
+}
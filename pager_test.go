@@ -0,0 +1,84 @@
+package main
+
+import (
+	"flag"
+	"github.com/codegangsta/cli"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+/// Replace stdout by a file standing in for the terminal, restored by the returned func
+func captureStdout(t *testing.T) (*os.File, func()) {
+	terminal, err := ioutil.TempFile("", "lab-terminal")
+	if nil != err {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = terminal
+	return terminal, func() {
+		os.Stdout = stdout
+		terminal.Close()
+		os.Remove(terminal.Name())
+	}
+}
+
+func TestRenderThroughPager(t *testing.T) {
+	terminal, restore := captureStdout(t)
+	defer restore()
+
+	set := flag.NewFlagSet("test", flag.ContinueOnError)
+	set.String("output", OUTPUT_JSON, "")
+	r, err := newRenderer(cli.NewContext(nil, set, nil), "test", "", nil, true)
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	// Like the commands, the renderer is made before the pager is started
+	stop := runPager("sed 's/^/paged: /'")
+	for _, item := range outputTestItems {
+		if err = r.render(item); nil != err {
+			t.Fatal(err)
+		}
+	}
+	if err = r.flush(); nil != err {
+		t.Fatal(err)
+	}
+	stop()
+
+	if os.Stdout != terminal {
+		t.Fatal("Expected stdout to be restored")
+	}
+	out, err := ioutil.ReadFile(terminal.Name())
+	if nil != err {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	if len(lines) != len(outputTestItems) {
+		t.Fatalf("Expected %d lines, got: %q\n", len(outputTestItems), string(out))
+	}
+	for _, line := range lines {
+		if !strings.HasPrefix(line, "paged: {") {
+			t.Fatalf("Expected output through the pager, got: %q\n", string(out))
+		}
+	}
+}
+
+func TestWriteToQuitPager(t *testing.T) {
+	_, restore := captureStdout(t)
+	defer restore()
+
+	stop := runPager("true")
+	defer stop()
+
+	// Fill the pipe until the pager is gone
+	chunk := []byte(strings.Repeat("x", 1024) + "\n")
+	var err error
+	for i := 0; i < 100000 && nil == err; i++ {
+		_, err = currentStdout{}.Write(chunk)
+	}
+	if !isBrokenPipe(err) {
+		t.Fatal("Expected a broken pipe, got:", err)
+	}
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/andrew-d/go-termutil"
+	"github.com/fatih/color"
+	"github.com/mattn/go-runewidth"
+	"github.com/nsf/termbox-go"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+/// Picking was cancelled, eg. by escape or end of input
+var ErrNoSelection = errors.New("Nothing selected")
+
+/// Item to pick, label in the list and text of the preview pane
+type pickerItem struct {
+	label   string
+	preview string
+}
+
+/// Merge requests labeled by the first line of format, previewed with their description
+func mergeRequestPickerItems(format string, mergeRequests []mergeRequest) ([]pickerItem, error) {
+	labelTmpl, err := newMonochromeTemplate("merge-request-picker-label", format)
+	if nil != err {
+		return nil, err
+	}
+	previewTmpl, err := newMonochromeTemplate("merge-request-picker-preview", MergeRequestPreviewTemplate)
+	if nil != err {
+		return nil, err
+	}
+
+	items := make([]pickerItem, len(mergeRequests))
+	for i, request := range mergeRequests {
+		var label, preview bytes.Buffer
+		err = labelTmpl.Execute(&label, request)
+		if nil != err {
+			return nil, err
+		}
+		err = previewTmpl.Execute(&preview, request)
+		if nil != err {
+			return nil, err
+		}
+		items[i] = pickerItem{
+			label:   strings.Split(strings.TrimSpace(label.String()), "\n")[0],
+			preview: preview.String(),
+		}
+	}
+	return items, nil
+}
+
+/// Let the user pick an item, fuzzy searching with a preview on a terminal, by number otherwise
+func pick(prompt string, items []pickerItem) (int, error) {
+	if len(items) == 0 {
+		return -1, ErrNoSelection
+	}
+	if termutil.Isatty(os.Stdin.Fd()) && termutil.Isatty(os.Stderr.Fd()) {
+		return pickInteractive(prompt, items)
+	}
+	return pickNumbered(prompt, items, os.Stdin, os.Stderr)
+}
+
+/// Print numbered items and read the number of one, ErrNoSelection on end of input
+func pickNumbered(prompt string, items []pickerItem, in io.Reader, out io.Writer) (int, error) {
+	for i, item := range items {
+		fmt.Fprintf(out, "%s %s\n", color.RedString("%d:", i), item.label)
+	}
+
+	reader := bufio.NewReader(in)
+	for {
+		fmt.Fprintf(out, "%s [0-%d]: ", prompt, len(items)-1)
+		line, err := reader.ReadString('\n')
+		if nil != err && line == "" {
+			fmt.Fprintln(out)
+			if err == io.EOF {
+				return -1, ErrNoSelection
+			}
+			return -1, err
+		}
+
+		choice, err := strconv.Atoi(strings.TrimSpace(line))
+		if nil == err && choice >= 0 && choice < len(items) {
+			return choice, nil
+		}
+		fmt.Fprintf(out, "Not a number between 0 and %d\n", len(items)-1)
+	}
+}
+
+/// Score of text matching the runes of each word of query in order, case insensitive, higher is better
+func fuzzyScore(query, text string) (int, bool) {
+	haystack := []rune(strings.ToLower(text))
+	total := 0
+	for _, word := range strings.Fields(strings.ToLower(query)) {
+		needle := []rune(word)
+		score, matched, last := 0, 0, -2
+		for i, r := range haystack {
+			if matched == len(needle) {
+				break
+			}
+			if r != needle[matched] {
+				continue
+			}
+			score++
+			if last == i-1 {
+				// Consecutive runes
+				score += 4
+			}
+			if i == 0 || !unicode.IsLetter(haystack[i-1]) && !unicode.IsDigit(haystack[i-1]) {
+				// Start of a word
+				score += 2
+			}
+			last = i
+			matched++
+		}
+		if matched < len(needle) {
+			return 0, false
+		}
+		total += score
+	}
+	return total, true
+}
+
+/// Indexes of items matching query, best first
+func fuzzyFilter(query string, items []pickerItem) []int {
+	var matches []int
+	scores := make(map[int]int)
+	for i, item := range items {
+		if score, ok := fuzzyScore(query, item.label); ok {
+			matches = append(matches, i)
+			scores[i] = score
+		}
+	}
+	sort.SliceStable(matches, func(a, b int) bool {
+		return scores[matches[a]] > scores[matches[b]]
+	})
+	return matches
+}
+
+/// Full screen picker: type to search, arrows to move, enter to pick, escape to cancel
+func pickInteractive(prompt string, items []pickerItem) (int, error) {
+	err := termbox.Init()
+	if nil != err {
+		return pickNumbered(prompt, items, os.Stdin, os.Stderr)
+	}
+	defer termbox.Close()
+
+	var query []rune
+	cursor, offset := 0, 0
+	for {
+		matches := fuzzyFilter(string(query), items)
+		if cursor >= len(matches) {
+			cursor = len(matches) - 1
+		}
+		if cursor < 0 {
+			cursor = 0
+		}
+		offset = drawPicker(prompt, string(query), items, matches, cursor, offset)
+
+		ev := termbox.PollEvent()
+		switch ev.Type {
+		case termbox.EventError:
+			return -1, ev.Err
+		case termbox.EventKey:
+			_, height := termbox.Size()
+			switch ev.Key {
+			case termbox.KeyEsc, termbox.KeyCtrlC:
+				return -1, ErrNoSelection
+			case termbox.KeyCtrlD:
+				if len(query) == 0 {
+					return -1, ErrNoSelection
+				}
+			case termbox.KeyEnter:
+				if len(matches) > 0 {
+					return matches[cursor], nil
+				}
+			case termbox.KeyArrowUp, termbox.KeyCtrlP:
+				cursor--
+			case termbox.KeyArrowDown, termbox.KeyCtrlN:
+				cursor++
+			case termbox.KeyPgup:
+				cursor -= height / 2
+			case termbox.KeyPgdn:
+				cursor += height / 2
+			case termbox.KeyBackspace, termbox.KeyBackspace2:
+				if len(query) > 0 {
+					query = query[:len(query)-1]
+				}
+			case termbox.KeyCtrlU:
+				query = nil
+			case termbox.KeySpace:
+				query = append(query, ' ')
+			default:
+				if ev.Ch != 0 {
+					query = append(query, ev.Ch)
+					cursor = 0
+				}
+			}
+		}
+	}
+}
+
+/// Draw prompt, list of matches and preview of the current one, returns the scroll offset of the list
+func drawPicker(prompt, query string, items []pickerItem, matches []int, cursor, offset int) int {
+	termbox.Clear(termbox.ColorDefault, termbox.ColorDefault)
+	width, height := termbox.Size()
+
+	x := drawText(0, 0, width, prompt+"> ", termbox.ColorBlue|termbox.AttrBold, termbox.ColorDefault)
+	x = drawText(x, 0, width, query, termbox.ColorDefault, termbox.ColorDefault)
+	termbox.SetCursor(x, 0)
+	drawText(0, 1, width, fmt.Sprintf("  %d/%d", len(matches), len(items)), termbox.ColorYellow, termbox.ColorDefault)
+
+	// List in the upper half, the preview below
+	listHeight := height - 2
+	if height >= 10 {
+		listHeight = (height - 3) / 2
+	}
+	if cursor < offset {
+		offset = cursor
+	}
+	if cursor >= offset+listHeight {
+		offset = cursor - listHeight + 1
+	}
+
+	for row := 0; row < listHeight && offset+row < len(matches); row++ {
+		fg, bg := termbox.ColorDefault, termbox.ColorDefault
+		marker := "  "
+		if offset+row == cursor {
+			fg, bg = termbox.ColorDefault|termbox.AttrReverse, termbox.ColorDefault
+			marker = "> "
+		}
+		line := marker + items[matches[offset+row]].label
+		if offset+row == cursor {
+			line += strings.Repeat(" ", width)
+		}
+		drawText(0, 2+row, width, line, fg, bg)
+	}
+
+	if height >= 10 && len(matches) > 0 {
+		top := 2 + listHeight
+		drawText(0, top, width, strings.Repeat("─", width), termbox.ColorBlue, termbox.ColorDefault)
+		for i, line := range wrapText(items[matches[cursor]].preview, width) {
+			if top+1+i >= height {
+				break
+			}
+			drawText(0, top+1+i, width, line, termbox.ColorDefault, termbox.ColorDefault)
+		}
+	}
+
+	termbox.Flush()
+	return offset
+}
+
+/// Draw text from x on line y, cut at width, returns x after the text
+func drawText(x, y, width int, text string, fg, bg termbox.Attribute) int {
+	for _, r := range text {
+		w := runewidth.RuneWidth(r)
+		if x+w > width {
+			break
+		}
+		termbox.SetCell(x, y, r, fg, bg)
+		x += w
+	}
+	return x
+}
+
+/// Wrap lines of text at width, expanding tabs
+func wrapText(text string, width int) []string {
+	var lines []string
+	for _, line := range strings.Split(strings.Replace(text, "\t", "    ", -1), "\n") {
+		for runewidth.StringWidth(line) > width && width > 0 {
+			cut, w := 0, 0
+			for i, r := range line {
+				if w+runewidth.RuneWidth(r) > width {
+					cut = i
+					break
+				}
+				w += runewidth.RuneWidth(r)
+			}
+			if cut == 0 {
+				break
+			}
+			lines = append(lines, line[:cut])
+			line = line[cut:]
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestPickNumbered(t *testing.T) {
+	items := []pickerItem{{label: "!1 Add picker"}, {label: "!2 Fix pager"}}
+
+	var out bytes.Buffer
+	i, err := pickNumbered("Merge request", items, strings.NewReader("-1\nfoo\n7\n1\n"), &out)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if i != 1 {
+		t.Fatal("Expected second item, got:", i)
+	}
+	if strings.Count(out.String(), "Not a number between 0 and 1") != 3 {
+		t.Fatal("Expected invalid choices to be rejected, got:", out.String())
+	}
+
+	_, err = pickNumbered("Merge request", items, strings.NewReader("foo\n"), &out)
+	if err != ErrNoSelection {
+		t.Fatal("Expected no selection on end of input, got:", err)
+	}
+}
+
+func TestFuzzyFilter(t *testing.T) {
+	items := []pickerItem{
+		{label: "!3 Update changelog"},
+		{label: "!4 Add merge request picker"},
+		{label: "!5 Pick diff of merge requests"},
+	}
+
+	matches := fuzzyFilter("mr pick", items)
+	if len(matches) != 2 || matches[0] != 1 {
+		t.Fatal("Expected picker first, got:", matches)
+	}
+
+	if matches := fuzzyFilter("", items); len(matches) != 3 || matches[0] != 0 {
+		t.Fatal("Expected all items in order for empty query, got:", matches)
+	}
+
+	if _, ok := fuzzyScore("xyz", items[0].label); ok {
+		t.Fatal("Expected no match")
+	}
+}
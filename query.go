@@ -0,0 +1,316 @@
+package main
+
+import (
+	"fmt"
+	"github.com/codegangsta/cli"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/// Flags for filtering, searching and sorting merge request lists
+var mergeRequestQueryFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "author",
+		Usage: "Filter by author username",
+	},
+	cli.StringFlag{
+		Name:  "assignee",
+		Usage: "Filter by assignee username, or: none, any",
+	},
+	cli.StringFlag{
+		Name:  "reviewer",
+		Usage: "Filter by reviewer username, or: none, any",
+	},
+	cli.StringSliceFlag{
+		Name:  "label, l",
+		Usage: "Filter by label, repeat for several",
+	},
+	cli.StringFlag{
+		Name:  "milestone",
+		Usage: "Filter by milestone title",
+	},
+	cli.StringFlag{
+		Name:  "target-branch",
+		Usage: "Filter by target branch",
+	},
+	cli.StringFlag{
+		Name:  "source-branch",
+		Usage: "Filter by source branch",
+	},
+	cli.BoolFlag{
+		Name:  "draft",
+		Usage: "Only draft merge requests",
+	},
+	cli.BoolFlag{
+		Name:  "ready",
+		Usage: "Only merge requests not marked as draft",
+	},
+	cli.BoolFlag{
+		Name:  "mine",
+		Usage: "Only merge requests authored by me",
+	},
+	cli.StringFlag{
+		Name:  "created-since",
+		Usage: "Created since date (2006-01-02) or duration (eg. 3d, 12h, 2w)",
+	},
+	cli.StringFlag{
+		Name:  "updated-since",
+		Usage: "Updated since date (2006-01-02) or duration (eg. 3d, 12h, 2w)",
+	},
+	cli.StringFlag{
+		Name:  "search, s",
+		Usage: "Search title and description",
+	},
+	cli.StringFlag{
+		Name:  "order-by",
+		Usage: "Order by: created_at, updated_at or title",
+	},
+	cli.StringFlag{
+		Name:  "sort",
+		Usage: "Sort direction: asc or desc",
+	},
+}
+
+/// Flags for filtering, searching and sorting issue lists
+var issueQueryFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "author",
+		Usage: "Filter by author username",
+	},
+	cli.StringFlag{
+		Name:  "assignee",
+		Usage: "Filter by assignee username, or: none, any",
+	},
+	cli.StringSliceFlag{
+		Name:  "label, l",
+		Usage: "Filter by label, repeat for several",
+	},
+	cli.StringFlag{
+		Name:  "milestone",
+		Usage: "Filter by milestone title",
+	},
+	cli.BoolFlag{
+		Name:  "mine",
+		Usage: "Only issues authored by me",
+	},
+	cli.StringFlag{
+		Name:  "created-since",
+		Usage: "Created since date (2006-01-02) or duration (eg. 3d, 12h, 2w)",
+	},
+	cli.StringFlag{
+		Name:  "updated-since",
+		Usage: "Updated since date (2006-01-02) or duration (eg. 3d, 12h, 2w)",
+	},
+	cli.StringFlag{
+		Name:  "search, s",
+		Usage: "Search title and description",
+	},
+	cli.StringFlag{
+		Name:  "order-by",
+		Usage: "Order by: created_at, updated_at or title",
+	},
+	cli.StringFlag{
+		Name:  "sort",
+		Usage: "Sort direction: asc or desc",
+	},
+}
+
+/// Flags for fields of created and updated issues
+var issueEditFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "message, m",
+		Usage: "Description, use - to read from stdin",
+	},
+	cli.StringSliceFlag{
+		Name:  "label, l",
+		Usage: "Label, repeat for several, replaces those of updated issues",
+	},
+	cli.StringSliceFlag{
+		Name:  "assignee, a",
+		Usage: "Assignee username, repeat for several, or: none",
+	},
+	cli.StringFlag{
+		Name:  "milestone",
+		Usage: "Milestone title, or: none",
+	},
+	cli.BoolFlag{
+		Name:  "confidential",
+		Usage: "Only visible to project members",
+	},
+	cli.BoolFlag{
+		Name:  "public",
+		Usage: "Not confidential",
+	},
+}
+
+/// Build merge request or issue api query from the state, filter and sort flags, those a command lacks are unset
+func queryFromFlags(c *cli.Context, server gitlab) (url.Values, error) {
+	query := url.Values{}
+
+	set := func(key, value string) {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	setUser := func(key, username string) {
+		switch strings.ToLower(username) {
+		case "":
+		case "none", "any":
+			query.Set(key+"_id", strings.Title(strings.ToLower(username)))
+		default:
+			query.Set(key+"_username", username)
+		}
+	}
+
+	set("state", c.String("state"))
+	setUser("author", c.String("author"))
+	setUser("assignee", c.String("assignee"))
+	setUser("reviewer", c.String("reviewer"))
+	set("labels", strings.Join(c.StringSlice("label"), ","))
+	set("milestone", c.String("milestone"))
+	set("target_branch", c.String("target-branch"))
+	set("source_branch", c.String("source-branch"))
+	set("search", c.String("search"))
+	set("order_by", c.String("order-by"))
+	set("sort", c.String("sort"))
+
+	switch {
+	case c.Bool("draft") && c.Bool("ready"):
+		return nil, fmt.Errorf("Use either --draft or --ready\n")
+	case c.Bool("draft"):
+		query.Set("wip", "yes")
+	case c.Bool("ready"):
+		query.Set("wip", "no")
+	}
+
+	if c.Bool("mine") {
+		if c.String("author") != "" {
+			return nil, fmt.Errorf("Use either --mine or --author\n")
+		}
+		me, err := server.getCurrentUser()
+		if nil != err {
+			return nil, err
+		}
+		query.Set("author_id", strconv.Itoa(me.Id))
+	}
+
+	now := time.Now()
+	for flag, key := range map[string]string{"created-since": "created_after", "updated-since": "updated_after"} {
+		if value := c.String(flag); value != "" {
+			since, err := parseSince(value, now)
+			if nil != err {
+				return nil, err
+			}
+			query.Set(key, since.Format(time.RFC3339))
+		}
+	}
+
+	return query, nil
+}
+
+/// Flags for filtering and sorting pipeline lists
+var pipelineQueryFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "status",
+		Usage: "Filter by status, eg. running, pending, success, failed, canceled, manual",
+	},
+	cli.StringFlag{
+		Name:  "ref",
+		Usage: "Filter by branch or tag",
+	},
+	cli.StringFlag{
+		Name:  "source",
+		Usage: "Filter by source, eg. push, web, schedule, merge_request_event",
+	},
+	cli.StringFlag{
+		Name:  "sha",
+		Usage: "Filter by commit SHA",
+	},
+	cli.StringFlag{
+		Name:  "username",
+		Usage: "Filter by username of the user triggering them",
+	},
+	cli.BoolFlag{
+		Name:  "mine",
+		Usage: "Only pipelines triggered by me",
+	},
+	cli.StringFlag{
+		Name:  "updated-since",
+		Usage: "Updated since date (2006-01-02) or duration (eg. 3d, 12h, 2w)",
+	},
+	cli.StringFlag{
+		Name:  "order-by",
+		Usage: "Order by: id, status, ref, updated_at or user_id",
+	},
+	cli.StringFlag{
+		Name:  "sort",
+		Usage: "Sort direction: asc or desc",
+	},
+}
+
+/// Build pipeline api query from the filter and sort flags
+func pipelineQueryFromFlags(c *cli.Context, server gitlab) (url.Values, error) {
+	query := url.Values{}
+	for flag, key := range map[string]string{
+		"status":   "status",
+		"ref":      "ref",
+		"source":   "source",
+		"sha":      "sha",
+		"username": "username",
+		"order-by": "order_by",
+		"sort":     "sort",
+	} {
+		if value := c.String(flag); value != "" {
+			query.Set(key, value)
+		}
+	}
+
+	if c.Bool("mine") {
+		if c.String("username") != "" {
+			return nil, fmt.Errorf("Use either --mine or --username\n")
+		}
+		me, err := server.getCurrentUser()
+		if nil != err {
+			return nil, err
+		}
+		query.Set("username", me.Username)
+	}
+
+	if value := c.String("updated-since"); value != "" {
+		since, err := parseSince(value, time.Now())
+		if nil != err {
+			return nil, err
+		}
+		query.Set("updated_after", since.Format(time.RFC3339))
+	}
+
+	return query, nil
+}
+
+/// Parse a date (2006-01-02), timestamp (RFC 3339) or a duration back from now (eg. 90m, 3d, 2w)
+func parseSince(value string, now time.Time) (time.Time, error) {
+	if t, err := time.ParseInLocation("2006-01-02", value, now.Location()); nil == err {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); nil == err {
+		return t, nil
+	}
+
+	units := map[byte]time.Duration{
+		'd': 24 * time.Hour,
+		'w': 7 * 24 * time.Hour,
+	}
+	if unit, ok := units[value[len(value)-1]]; ok {
+		n, err := strconv.Atoi(value[:len(value)-1])
+		if nil == err {
+			return now.Add(-time.Duration(n) * unit), nil
+		}
+	}
+	if d, err := time.ParseDuration(value); nil == err {
+		return now.Add(-d), nil
+	}
+
+	return time.Time{}, fmt.Errorf("Not a date or duration: %s\n", value)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"flag"
+	"github.com/codegangsta/cli"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2015, 12, 9, 12, 0, 0, 0, time.UTC)
+
+	for value, expected := range map[string]time.Time{
+		"2015-11-30":           time.Date(2015, 11, 30, 0, 0, 0, 0, time.UTC),
+		"2015-11-30T10:00:00Z": time.Date(2015, 11, 30, 10, 0, 0, 0, time.UTC),
+		"3d":                   time.Date(2015, 12, 6, 12, 0, 0, 0, time.UTC),
+		"2w":                   time.Date(2015, 11, 25, 12, 0, 0, 0, time.UTC),
+		"90m":                  time.Date(2015, 12, 9, 10, 30, 0, 0, time.UTC),
+	} {
+		since, err := parseSince(value, now)
+		if nil != err {
+			t.Fatal(err)
+		}
+		if !since.Equal(expected) {
+			t.Fatalf("Expected %s to parse as %s, got: %s\n", value, expected, since)
+		}
+	}
+
+	if _, err := parseSince("yesterday", now); nil == err {
+		t.Fatal("Expected error for unparsable value")
+	}
+}
+
+func TestPageLimit(t *testing.T) {
+	set := flag.NewFlagSet("test", flag.ContinueOnError)
+	set.Int("limit", 0, "")
+	set.Parse([]string{"--limit", "150"})
+	query := url.Values{}
+	limit := newPageLimit(cli.NewContext(nil, set, nil), query)
+	if query.Get("per_page") != "" {
+		t.Fatal("Expected full pages for a limit over a page, got:", query.Get("per_page"))
+	}
+
+	if n := limit.take(100); n != 100 || nil != limit.next() {
+		t.Fatal("Expected the whole first page and more, got:", n)
+	}
+	if n := limit.take(100); n != 50 || limit.next() != ErrStopPaging {
+		t.Fatal("Expected the rest of the limit of the second page and to stop, got:", n)
+	}
+
+	set.Parse([]string{"--limit", "20"})
+	newPageLimit(cli.NewContext(nil, set, nil), query)
+	if query.Get("per_page") != "20" {
+		t.Fatal("Expected pages of the limit, got:", query.Get("per_page"))
+	}
+}
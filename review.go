@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/codegangsta/cli"
+	"log"
+	"os"
+	"strings"
+	"text/template"
+)
+
+const reviewFileHelp = "(n)ext, (p)revious, (c)omment <line> [text], (q)uit: "
+const reviewVerdictHelp = "(a)pprove, (r)equest changes, (c)omment, (k)eep drafts, (d)iscard drafts: "
+
+/// Walk the merge request diff file by file, collecting comments as draft notes
+func reviewMergeRequest(c *cli.Context, server gitlab, projectId string, req mergeRequest) error {
+	version, err := server.getMergeRequestVersion(projectId, req.Iid, c.Int("version"))
+	if nil != err {
+		return err
+	}
+	if len(version.Diffs) == 0 {
+		return fmt.Errorf("No changes to review in !%d\n", req.Iid)
+	}
+
+	tmpl, err := newTemplate("diff-line", DiffLineTemplate, doColors(os.Stdout))
+	if nil != err {
+		return err
+	}
+
+	drafts, err := server.getDraftNotes(projectId, req.Iid)
+	if nil != err {
+		return err
+	}
+	if len(drafts) > 0 {
+		log.Printf("Continuing review with %d draft comments\n", len(drafts))
+	}
+
+	input := bufio.NewReader(os.Stdin)
+	for i := 0; i >= 0 && i < len(version.Diffs); {
+		step, err := reviewFile(input, tmpl, server, projectId, req, *version, i)
+		if nil != err {
+			return err
+		}
+		if step == 0 {
+			break
+		}
+		if i+step >= 0 {
+			i += step
+		}
+	}
+
+	return finishReview(c, input, server, projectId, req, *version)
+}
+
+/// Show diff of a single file and prompt for comments, returns the step to the next file or 0 to stop
+func reviewFile(input *bufio.Reader, tmpl *template.Template, server gitlab, projectId string, req mergeRequest, version mergeRequestVersion, index int) (int, error) {
+	diff := version.Diffs[index]
+	lines := parseDiff(diff.Diff)
+
+	fmt.Printf("\n%s (%d/%d)\n", diff.Path(), index+1, len(version.Diffs))
+	for _, line := range lines {
+		err := tmpl.Execute(os.Stdout, line)
+		if nil != err {
+			return 0, err
+		}
+	}
+
+	for {
+		fmt.Fprint(os.Stderr, reviewFileHelp)
+		answer, err := input.ReadString('\n')
+		if nil != err && answer == "" {
+			// EOF, finish the review
+			fmt.Fprintln(os.Stderr)
+			return 0, nil
+		}
+
+		fields := strings.Fields(answer)
+		if len(fields) == 0 {
+			return 1, nil
+		}
+
+		switch fields[0] {
+		case "n":
+			return 1, nil
+		case "p":
+			return -1, nil
+		case "q":
+			return 0, nil
+		case "c":
+			if len(fields) < 2 {
+				fmt.Fprintln(os.Stderr, "Which line? Eg. \"c 42\", or \"c -42\" for a removed line")
+				continue
+			}
+			line, err := findDiffLine(lines, fields[1])
+			if nil != err {
+				fmt.Fprint(os.Stderr, err)
+				continue
+			}
+
+			position := version.position(diff, *line)
+			text := strings.Join(fields[2:], " ")
+			if text == "" {
+				text, err = editMessage("", "Comment on "+position.Location()+":\n"+line.Text)
+				if err == ErrEmptyMessage {
+					fmt.Fprintln(os.Stderr, err)
+					continue
+				}
+				if nil != err {
+					return 0, err
+				}
+			}
+
+			_, err = server.createDraftNote(projectId, req.Iid, draftNoteCreateRequest{
+				Note:     text,
+				Position: &position,
+			})
+			if nil != err {
+				return 0, err
+			}
+			fmt.Fprintf(os.Stderr, "Draft comment on %s\n", position.Location())
+		default:
+			fmt.Fprintf(os.Stderr, "Unknown command: %s\n", fields[0])
+		}
+	}
+}
+
+/// Publish draft comments with a verdict, or keep/discard them
+func finishReview(c *cli.Context, input *bufio.Reader, server gitlab, projectId string, req mergeRequest, version mergeRequestVersion) error {
+	drafts, err := server.getDraftNotes(projectId, req.Iid)
+	if nil != err {
+		return err
+	}
+
+	verdict := c.String("verdict")
+	for verdict == "" {
+		fmt.Fprintf(os.Stderr, "%d draft comments. %s", len(drafts), reviewVerdictHelp)
+		answer, err := input.ReadString('\n')
+		if nil != err && answer == "" {
+			// EOF, never throw away comments
+			verdict = "keep"
+			break
+		}
+
+		switch strings.TrimSpace(answer) {
+		case "a":
+			verdict = "approve"
+		case "r":
+			verdict = "request-changes"
+		case "c":
+			verdict = "comment"
+		case "k":
+			verdict = "keep"
+		case "d":
+			verdict = "discard"
+		}
+	}
+
+	switch verdict {
+	case "approve", "comment":
+	case "request-changes":
+		summary, err := editMessage("", fmt.Sprintf("Summary of requested changes for !%d: %s", req.Iid, req.Title))
+		if nil != err {
+			return err
+		}
+		_, err = server.createDraftNote(projectId, req.Iid, draftNoteCreateRequest{Note: summary})
+		if nil != err {
+			return err
+		}
+		drafts = append(drafts, draftNote{Note: summary})
+	case "keep":
+		log.Printf("Kept %d draft comments on !%d\n", len(drafts), req.Iid)
+		return nil
+	case "discard":
+		for _, draft := range drafts {
+			err = server.deleteDraftNote(projectId, req.Iid, draft.Id)
+			if nil != err {
+				return err
+			}
+		}
+		log.Printf("Discarded %d draft comments on !%d\n", len(drafts), req.Iid)
+		return nil
+	default:
+		return fmt.Errorf("Unknown verdict: %s\n", verdict)
+	}
+
+	if len(drafts) > 0 {
+		err = server.publishDraftNotes(projectId, req.Iid)
+		if nil != err {
+			return err
+		}
+		log.Printf("Published %d comments on !%d\n", len(drafts), req.Iid)
+	}
+
+	switch verdict {
+	case "approve":
+		_, err = server.approveMergeRequest(projectId, req.Iid, version.HeadCommitSha)
+		if nil != err {
+			return err
+		}
+		log.Printf("Approved !%d\n", req.Iid)
+	case "request-changes":
+		approvals, err := server.getMergeRequestApprovals(projectId, req.Iid)
+		if nil != err {
+			return err
+		}
+		if approvals != nil && approvals.UserHasApproved {
+			err = server.unapproveMergeRequest(projectId, req.Iid)
+			if nil != err {
+				return err
+			}
+			log.Printf("Withdrew approval of !%d\n", req.Iid)
+		}
+	}
+
+	return nil
+}
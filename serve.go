@@ -0,0 +1,352 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/codegangsta/cli"
+	"html/template"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/// Dashboard of one GitLab instance, as shown by lab serve
+type instanceDashboard struct {
+	Host      string         `json:"host"`
+	Url       string         `json:"url"`
+	User      *user          `json:"user"`
+	Assigned  []mergeRequest `json:"assigned"`
+	Reviewing []mergeRequest `json:"reviewing"`
+	Created   []mergeRequest `json:"created"`
+	Project   string         `json:"project,omitempty"`
+	Pipelines []pipeline     `json:"pipelines,omitempty"`
+	Activity  []*feedCommit  `json:"activity"`
+	Error     string         `json:"error,omitempty"`
+}
+
+/// Serves the dashboard of the instances, and proxies read requests to their apis with the private token
+type dashboardServer struct {
+	instances []gitlab
+	project   string // Path of the current project, on the first instance
+	listen    string
+	refresh   time.Duration
+
+	mutex  sync.Mutex
+	loaded time.Time
+	cached []instanceDashboard
+}
+
+/// Start local web interface for the current project's instance and the [[instances]] configured
+func serveDashboard(c *cli.Context) {
+	s := &dashboardServer{
+		listen:  c.String("listen"),
+		refresh: time.Duration(c.Int("refresh")) * time.Second,
+	}
+
+	host, _, err := net.SplitHostPort(s.listen)
+	if nil != err {
+		log.Fatal(err)
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (nil == ip || !ip.IsLoopback()) {
+		log.Fatalf("Not listening on %s, the proxy uses your private tokens: listen on localhost only\n", s.listen)
+	}
+
+	// The instance of the current project, when run in one
+	if remoteUrl, err := needGitDir(c).getRemoteUrl(c.String("remote")); nil == err {
+		remote := parseRemote(remoteUrl)
+		if isGitlabHost(remote.base) {
+			server := newGitlab(remote.base)
+			server.token = needToken(c)
+			s.instances = append(s.instances, server)
+			s.project = remote.path
+		}
+	}
+
+	config, err := readConfig(c)
+	if nil != err {
+		log.Fatal(err)
+	}
+	for _, instance := range config.Instances {
+		server, err := instance.gitlab()
+		if nil != err {
+			log.Fatal(err)
+		}
+		if nil == s.instance(server.host) {
+			s.instances = append(s.instances, server)
+		}
+	}
+
+	if len(s.instances) == 0 {
+		log.Fatal("No GitLab instance, run in a project with a GitLab remote, or add [[instances]] with url and private_token to ~/.labrc")
+	}
+
+	addr := "http://" + s.listen + "/"
+	log.Printf("Serving dashboard of %d instance(s) on %s\n", len(s.instances), addr)
+	if c.Bool("open") {
+		go browse(addr)
+	}
+	log.Fatal(http.ListenAndServe(s.listen, s))
+}
+
+/// Get instance by host, nil if unknown
+func (s *dashboardServer) instance(host string) *gitlab {
+	for i := range s.instances {
+		if s.instances[i].host == host {
+			return &s.instances[i]
+		}
+	}
+	return nil
+}
+
+func (s *dashboardServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Other sites may resolve their names to localhost, only answer for our own
+	if !s.allowedHost(r.Host) {
+		http.Error(w, "Unknown host: "+r.Host, http.StatusForbidden)
+		return
+	}
+
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/proxy/"):
+		s.proxy(w, r)
+	case r.URL.Path == "/dashboard.json":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s.dashboards(r.URL.Query().Get("refresh") != ""))
+	case r.URL.Path == "/":
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		err := dashboardPage.Execute(w, s.dashboards(r.URL.Query().Get("refresh") != ""))
+		if nil != err {
+			log.Println(err)
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *dashboardServer) allowedHost(host string) bool {
+	_, port, err := net.SplitHostPort(s.listen)
+	if nil != err {
+		return false
+	}
+	for _, allowed := range []string{s.listen, "localhost:" + port, "127.0.0.1:" + port, "[::1]:" + port} {
+		if host == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+/// Proxy /proxy/<host>/api/... to the api of the instance, reading only
+func (s *dashboardServer) proxy(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		http.Error(w, "Only GET and HEAD are proxied", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Pages of other sites must not read the api with the private token
+	if !sameOrigin(r) {
+		http.Error(w, "Cross-origin requests are not proxied", http.StatusForbidden)
+		return
+	}
+
+	rest := strings.SplitN(strings.TrimPrefix(r.URL.EscapedPath(), "/proxy/"), "/", 2)
+	instance := s.instance(rest[0])
+	if nil == instance || len(rest) < 2 || !strings.HasPrefix(rest[1], "api/") {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Keep escaped path segments, eg. "group%2Fproject"
+	path, err := url.PathUnescape("/" + rest[1])
+	if nil != err || strings.Contains(path, "..") {
+		http.Error(w, "Invalid path", http.StatusBadRequest)
+		return
+	}
+
+	proxy := &httputil.ReverseProxy{
+		Director: func(req *http.Request) {
+			req.URL.Scheme = instance.scheme
+			req.URL.Host = instance.host
+			req.URL.Path = path
+			req.URL.RawPath = "/" + rest[1]
+			req.Host = instance.host
+			req.Header.Del("Cookie")
+			req.Header.Del("Origin")
+			req.Header.Set("PRIVATE-TOKEN", instance.token)
+		},
+		// Served from the dashboard's origin: keep the instance's session and CORS out, and never run what it returns
+		ModifyResponse: func(resp *http.Response) error {
+			resp.Header.Del("Set-Cookie")
+			for name := range resp.Header {
+				if strings.HasPrefix(name, "Access-Control-") {
+					resp.Header.Del(name)
+				}
+			}
+			resp.Header.Set("X-Content-Type-Options", "nosniff")
+			resp.Header.Set("Content-Security-Policy", "sandbox")
+			return nil
+		},
+	}
+	proxy.ServeHTTP(w, r)
+}
+
+/// Request from the dashboard itself or typed in by the user, not by a page of another site
+func sameOrigin(r *http.Request) bool {
+	switch r.Header.Get("Sec-Fetch-Site") {
+	case "", "same-origin", "none":
+	default:
+		return false
+	}
+	origin := r.Header.Get("Origin")
+	return origin == "" || origin == "http://"+r.Host
+}
+
+/// Get dashboards of all instances, loading them again when older than --refresh
+func (s *dashboardServer) dashboards(reload bool) []instanceDashboard {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if !reload && nil != s.cached && time.Since(s.loaded) < s.refresh {
+		return s.cached
+	}
+
+	dashboards := make([]instanceDashboard, len(s.instances))
+	var wg sync.WaitGroup
+	for i, instance := range s.instances {
+		wg.Add(1)
+		go func(i int, instance gitlab) {
+			defer wg.Done()
+			project := ""
+			if i == 0 {
+				project = s.project
+			}
+			dashboards[i] = loadInstanceDashboard(instance, project)
+		}(i, instance)
+	}
+	wg.Wait()
+
+	s.cached = dashboards
+	s.loaded = time.Now()
+	return dashboards
+}
+
+/// Load merge requests of the current user across projects, pipelines of the project if given, and activity
+func loadInstanceDashboard(server gitlab, project string) instanceDashboard {
+	d := instanceDashboard{
+		Host:    server.host,
+		Url:     server.getProjectUrl(""),
+		Project: project,
+	}
+	fail := func(err error) instanceDashboard {
+		d.Error = strings.TrimSpace(err.Error())
+		return d
+	}
+
+	var err error
+	d.User, err = server.getCurrentUser()
+	if nil != err {
+		return fail(err)
+	}
+
+	for _, list := range []struct {
+		key      string
+		requests *[]mergeRequest
+	}{
+		{"assignee_id", &d.Assigned},
+		{"reviewer_id", &d.Reviewing},
+		{"author_id", &d.Created},
+	} {
+		*list.requests, err = server.queryGlobalMergeRequests("", url.Values{
+			"scope":  {"all"},
+			list.key: {strconv.Itoa(d.User.Id)},
+		})
+		if nil != err {
+			return fail(err)
+		}
+	}
+
+	if project != "" {
+		d.Pipelines, err = server.getPipelines(project, url.Values{"per_page": {"20"}})
+		if nil != err {
+			return fail(err)
+		}
+	}
+
+	activity, err := server.getActivityFeed(server.getFeedUrl())
+	if nil != err {
+		return fail(fmt.Errorf("Could not get activity: %s", err))
+	}
+	d.Activity = activity.Entries
+
+	return d
+}
+
+var dashboardPage = template.Must(template.New("dashboard").Funcs(template.FuncMap{
+	"ago":         ago,
+	"shortDate":   shortDate,
+	"usernames":   usernames,
+	"statusColor": statusColor,
+	"list": func(values ...interface{}) []interface{} {
+		return values
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>lab dashboard</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #333; }
+h1 { font-size: 1.4em; }
+h2 { font-size: 1.2em; border-bottom: 1px solid #ddd; padding-bottom: .2em; }
+h3 { font-size: 1em; margin-top: 1.5em; }
+table { border-collapse: collapse; width: 100%; }
+td { padding: .25em .5em; border-bottom: 1px solid #eee; vertical-align: top; }
+a { color: #1f78d1; text-decoration: none; }
+.muted { color: #888; }
+.green { color: #1aaa55; }
+.red { color: #db3b21; }
+.yellow { color: #c17d10; }
+.error { color: #db3b21; }
+</style>
+</head>
+<body>
+<h1>lab dashboard <a class="muted" href="?refresh=1">refresh</a></h1>
+{{ range . }}
+<h2><a href="{{ .Url }}">{{ .Host }}</a>{{ with .User }} <span class="muted">@{{ .Username }}</span>{{ end }}</h2>
+{{ with .Error }}<p class="error">{{ . }}</p>{{ end }}
+{{ template "requests" (list "Assigned to me" .Assigned) }}
+{{ template "requests" (list "Awaiting my review" .Reviewing) }}
+{{ template "requests" (list "Created by me" .Created) }}
+{{ with .Pipelines }}
+<h3>Pipelines of {{ $.Project }}</h3>
+<table>
+{{ range . }}<tr><td><a href="{{ .WebUrl }}">#{{ .Id }}</a></td><td class="{{ statusColor .Status }}">{{ .Status }}</td><td>{{ .Ref }}</td><td class="muted">{{ printf "%.8s" .Sha }}</td></tr>
+{{ end }}</table>
+{{ end }}
+{{ with .Activity }}
+<h3>Activity</h3>
+<table>
+{{ range . }}<tr><td class="muted">{{ shortDate .Updated }}</td><td>{{ if .Link.Href }}<a href="{{ .Link.Href }}">{{ .Title }}</a>{{ else }}{{ .Title }}{{ end }}</td></tr>
+{{ end }}</table>
+{{ end }}
+{{ end }}
+</body>
+</html>
+{{ define "requests" }}{{ $title := index . 0 }}{{ $requests := index . 1 }}
+<h3>{{ $title }} <span class="muted">{{ len $requests }}</span></h3>
+{{ if $requests }}<table>
+{{ range $requests }}<tr>
+<td class="muted">{{ .ProjectPath }}</td>
+<td><a href="{{ .WebUrl }}">!{{ .Iid }}</a></td>
+<td>{{ .Title }}{{ if .IsDraft }} <span class="muted">draft</span>{{ end }}</td>
+<td class="muted">{{ usernames .Author }}</td>
+<td class="{{ statusColor .PipelineStatus }}">{{ .PipelineStatus }}</td>
+<td class="muted">{{ ago .UpdatedAt }}</td>
+</tr>
+{{ end }}</table>{{ end }}
+{{ end }}`))
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	. "github.com/smartystreets/goconvey/convey"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDashboardServer(t *testing.T) {
+	Convey("Given a dashboard server for a gitlab instance", t, func() {
+		var upstream *http.Request
+		mux := http.NewServeMux()
+		mux.HandleFunc("/api/v4/user", func(w http.ResponseWriter, r *http.Request) {
+			json.NewEncoder(w).Encode(user{Id: 7, Username: "alice"})
+		})
+		mux.HandleFunc("/api/v4/merge_requests", func(w http.ResponseWriter, r *http.Request) {
+			requests := []mergeRequest{}
+			if r.URL.Query().Get("reviewer_id") == "7" {
+				requests = append(requests, mergeRequest{Iid: 3, Title: "Review me", WebUrl: "http://gitlab/group/lab/merge_requests/3"})
+			}
+			json.NewEncoder(w).Encode(requests)
+		})
+		mux.HandleFunc("/dashboard.atom", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`<feed><title>Dashboard</title><entry><title>alice pushed to master</title><link href="http://gitlab/group/lab"/></entry></feed>`))
+		})
+		mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.EscapedPath() == "/api/v4/projects/group%2Flab/pipelines" {
+				json.NewEncoder(w).Encode([]pipeline{pipeline{Id: 42, Status: "success"}})
+				return
+			}
+			upstream = r
+			http.SetCookie(w, &http.Cookie{Name: "_gitlab_session", Value: "secret"})
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			w.Write([]byte("[]"))
+		})
+		sr := httptest.NewServer(mux)
+		defer sr.Close()
+
+		u := urlMustParse(t, sr.URL)
+		g := newGitlab(u.Host)
+		g.token = "my-private-token"
+		s := &dashboardServer{
+			instances: []gitlab{g},
+			project:   "group/lab",
+			listen:    "localhost:7070",
+			refresh:   time.Minute,
+		}
+
+		get := func(method, path, host string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(method, path, nil)
+			req.Host = host
+			rec := httptest.NewRecorder()
+			s.ServeHTTP(rec, req)
+			return rec
+		}
+
+		Convey("The dashboard should have merge requests, pipelines and activity", func() {
+			rec := get("GET", "/dashboard.json", "localhost:7070")
+			So(rec.Code, ShouldEqual, 200)
+
+			var dashboards []instanceDashboard
+			So(json.NewDecoder(rec.Body).Decode(&dashboards), ShouldBeNil)
+			So(dashboards, ShouldHaveLength, 1)
+			So(dashboards[0].Error, ShouldEqual, "")
+			So(dashboards[0].Reviewing, ShouldHaveLength, 1)
+			So(dashboards[0].Assigned, ShouldHaveLength, 0)
+			So(dashboards[0].Pipelines[0].Id, ShouldEqual, 42)
+			So(dashboards[0].Activity[0].Link.Href, ShouldEqual, "http://gitlab/group/lab")
+
+			rec = get("GET", "/", "127.0.0.1:7070")
+			So(rec.Code, ShouldEqual, 200)
+			So(rec.Body.String(), ShouldContainSubstring, "Review me")
+		})
+
+		Convey("Api requests should be proxied with the private token", func() {
+			rec := get("GET", "/proxy/"+u.Host+"/api/v4/projects/group%2Fother/issues?state=opened", "localhost:7070")
+			So(rec.Code, ShouldEqual, 200)
+			So(upstream.URL.EscapedPath(), ShouldEqual, "/api/v4/projects/group%2Fother/issues")
+			So(upstream.URL.RawQuery, ShouldEqual, "state=opened")
+			So(upstream.Header.Get("PRIVATE-TOKEN"), ShouldEqual, "my-private-token")
+			So(rec.Header().Get("Set-Cookie"), ShouldEqual, "")
+			So(rec.Header().Get("Access-Control-Allow-Origin"), ShouldEqual, "")
+			So(rec.Header().Get("X-Content-Type-Options"), ShouldEqual, "nosniff")
+			So(rec.Header().Get("Content-Security-Policy"), ShouldEqual, "sandbox")
+		})
+
+		Convey("Requests of pages of other sites should be refused", func() {
+			path := "/proxy/" + u.Host + "/api/v4/projects/group%2Fother/issues"
+			req := httptest.NewRequest("GET", path, nil)
+			req.Host = "localhost:7070"
+			req.Header.Set("Origin", "http://evil.example.com")
+			rec := httptest.NewRecorder()
+			s.ServeHTTP(rec, req)
+			So(rec.Code, ShouldEqual, 403)
+
+			req = httptest.NewRequest("GET", path, nil)
+			req.Host = "localhost:7070"
+			req.Header.Set("Sec-Fetch-Site", "cross-site")
+			rec = httptest.NewRecorder()
+			s.ServeHTTP(rec, req)
+			So(rec.Code, ShouldEqual, 403)
+
+			req = httptest.NewRequest("GET", path, nil)
+			req.Host = "localhost:7070"
+			req.Header.Set("Origin", "http://localhost:7070")
+			req.Header.Set("Sec-Fetch-Site", "same-origin")
+			rec = httptest.NewRecorder()
+			s.ServeHTTP(rec, req)
+			So(rec.Code, ShouldEqual, 200)
+			So(upstream.Header.Get("Origin"), ShouldEqual, "")
+		})
+
+		Convey("Writes, other paths, unknown instances and foreign hosts should be refused", func() {
+			So(get("POST", "/proxy/"+u.Host+"/api/v4/projects", "localhost:7070").Code, ShouldEqual, 405)
+			So(get("GET", "/proxy/"+u.Host+"/profile/account", "localhost:7070").Code, ShouldEqual, 404)
+			So(get("GET", "/proxy/gitlab.example.com/api/v4/user", "localhost:7070").Code, ShouldEqual, 404)
+			So(get("GET", "/proxy/"+u.Host+"/api/v4/%2E%2E/user", "localhost:7070").Code, ShouldEqual, 400)
+			rec := get("GET", "/dashboard.json", "evil.example.com:7070")
+			So(rec.Code, ShouldEqual, 403)
+			So(strings.Contains(rec.Body.String(), "alice"), ShouldBeFalse)
+		})
+	})
+}
@@ -0,0 +1,397 @@
+package main
+
+import (
+	"fmt"
+	"github.com/mattn/go-runewidth"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const tableGap string = "  "
+const tableMinFlexWidth int = 10
+
+/// Column of a table, cells are padded and truncated before coloring
+type tableColumn struct {
+	header string
+	flex   bool // shrinks to fit the terminal width
+	value  func(item interface{}) string
+	color  func(value string) string // name of color func for the cell, "" for none
+}
+
+/// Table buffering rows until written, to align columns
+type table struct {
+	defs    map[string]tableColumn
+	columns []string
+	colors  bool
+	width   int
+	rows    [][]string
+}
+
+/// Named merge request columns, other names are looked up as api fields, eg. "milestone.title"
+var mergeRequestTableColumns = map[string]tableColumn{
+	"iid": {
+		header: "IID",
+		value:  func(item interface{}) string { return "#" + strconv.Itoa(item.(mergeRequest).Iid) },
+		color:  fixedColor("yellow"),
+	},
+	"title": {
+		header: "TITLE",
+		flex:   true,
+		value: func(item interface{}) string {
+			r := item.(mergeRequest)
+			if r.IsDraft() {
+				return "Draft: " + r.Title
+			}
+			return r.Title
+		},
+	},
+	"author": {
+		header: "AUTHOR",
+		value:  func(item interface{}) string { return usernames(item.(mergeRequest).Author) },
+		color:  fixedColor("blue"),
+	},
+	"assignees": {
+		header: "ASSIGNEES",
+		value:  func(item interface{}) string { return usernames(item.(mergeRequest).AllAssignees()) },
+		color:  fixedColor("blue"),
+	},
+	"reviewers": {
+		header: "REVIEWERS",
+		value:  func(item interface{}) string { return usernames(item.(mergeRequest).Reviewers) },
+		color:  fixedColor("blue"),
+	},
+	"branches": {
+		header: "BRANCHES",
+		flex:   true,
+		value: func(item interface{}) string {
+			r := item.(mergeRequest)
+			return r.SourceBranch + " -> " + r.TargetBranch
+		},
+	},
+	"pipeline": {
+		header: "PIPELINE",
+		value:  func(item interface{}) string { return item.(mergeRequest).PipelineStatus() },
+		color:  statusColor,
+	},
+	"state": {
+		header: "STATE",
+		value:  func(item interface{}) string { return item.(mergeRequest).State },
+		color:  statusColor,
+	},
+	"labels": {
+		header: "LABELS",
+		flex:   true,
+		value:  func(item interface{}) string { return strings.Join(item.(mergeRequest).Labels, ", ") },
+		color:  fixedColor("magenta"),
+	},
+	"project": {
+		header: "PROJECT",
+		value:  func(item interface{}) string { return item.(mergeRequest).ProjectPath },
+		color:  fixedColor("cyan"),
+	},
+	"age": {
+		header: "AGE",
+		value:  func(item interface{}) string { return shortAge(item.(mergeRequest).CreatedAt, time.Now()) },
+		color:  fixedColor("magenta"),
+	},
+	"updated": {
+		header: "UPDATED",
+		value:  func(item interface{}) string { return shortAge(item.(mergeRequest).UpdatedAt, time.Now()) },
+		color:  fixedColor("magenta"),
+	},
+}
+
+var mergeRequestTableDefaultColumns = []string{"iid", "title", "author", "branches", "pipeline", "age"}
+
+/// Named issue columns, other names are looked up as api fields
+var issueTableColumns = map[string]tableColumn{
+	"iid": {
+		header: "IID",
+		value:  func(item interface{}) string { return "#" + strconv.Itoa(item.(issue).Iid) },
+		color:  fixedColor("yellow"),
+	},
+	"title": {
+		header: "TITLE",
+		flex:   true,
+		value:  func(item interface{}) string { return item.(issue).Title },
+	},
+	"author": {
+		header: "AUTHOR",
+		value:  func(item interface{}) string { return usernames(item.(issue).Author) },
+		color:  fixedColor("blue"),
+	},
+	"assignees": {
+		header: "ASSIGNEES",
+		value:  func(item interface{}) string { return usernames(item.(issue).AllAssignees()) },
+		color:  fixedColor("blue"),
+	},
+	"state": {
+		header: "STATE",
+		value:  func(item interface{}) string { return item.(issue).State },
+		color:  statusColor,
+	},
+	"labels": {
+		header: "LABELS",
+		flex:   true,
+		value:  func(item interface{}) string { return strings.Join(item.(issue).Labels, ", ") },
+		color:  fixedColor("magenta"),
+	},
+	"milestone": {
+		header: "MILESTONE",
+		value: func(item interface{}) string {
+			if m := item.(issue).Milestone; m != nil {
+				return m.Title
+			}
+			return ""
+		},
+	},
+	"age": {
+		header: "AGE",
+		value:  func(item interface{}) string { return shortAge(item.(issue).CreatedAt, time.Now()) },
+		color:  fixedColor("magenta"),
+	},
+	"updated": {
+		header: "UPDATED",
+		value:  func(item interface{}) string { return shortAge(item.(issue).UpdatedAt, time.Now()) },
+		color:  fixedColor("magenta"),
+	},
+}
+
+var issueTableDefaultColumns = []string{"iid", "title", "author", "labels", "age"}
+
+/// Named pipeline columns, other names are looked up as api fields
+var pipelineTableColumns = map[string]tableColumn{
+	"id": {
+		header: "ID",
+		value:  func(item interface{}) string { return "#" + strconv.Itoa(item.(pipeline).Id) },
+		color:  fixedColor("yellow"),
+	},
+	"status": {
+		header: "STATUS",
+		value:  func(item interface{}) string { return item.(pipeline).Status },
+		color:  statusColor,
+	},
+	"ref": {
+		header: "REF",
+		flex:   true,
+		value:  func(item interface{}) string { return item.(pipeline).Ref },
+		color:  fixedColor("green"),
+	},
+	"sha": {
+		header: "SHA",
+		value: func(item interface{}) string {
+			sha := item.(pipeline).Sha
+			if len(sha) > 8 {
+				return sha[:8]
+			}
+			return sha
+		},
+	},
+	"source": {
+		header: "SOURCE",
+		value:  func(item interface{}) string { return item.(pipeline).Source },
+	},
+	"user": {
+		header: "USER",
+		value:  func(item interface{}) string { return usernames(item.(pipeline).User) },
+		color:  fixedColor("blue"),
+	},
+	"duration": {
+		header: "DURATION",
+		value: func(item interface{}) string {
+			if seconds := item.(pipeline).Duration; seconds > 0 {
+				return duration(seconds)
+			}
+			return ""
+		},
+	},
+	"age": {
+		header: "AGE",
+		value:  func(item interface{}) string { return shortAge(item.(pipeline).CreatedAt, time.Now()) },
+		color:  fixedColor("magenta"),
+	},
+}
+
+var pipelineTableDefaultColumns = []string{"id", "status", "ref", "sha", "source", "age"}
+
+func fixedColor(name string) func(string) string {
+	return func(string) string {
+		return name
+	}
+}
+
+/// Color for pipeline and merge request states
+func statusColor(status string) string {
+	switch status {
+	case "success", "merged", "opened", "reopened":
+		return "green"
+	case "failed", "canceled", "closed":
+		return "red"
+	case "running", "pending", "created", "preparing", "waiting_for_resource", "manual", "scheduled":
+		return "yellow"
+	}
+	return ""
+}
+
+/// Age of t, eg. "45m", "3h", "2d", "5mo", "1y"
+func shortAge(t time.Time, now time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	d := now.Sub(t)
+	switch {
+	case d < time.Minute:
+		return "now"
+	case d < time.Hour:
+		return strconv.Itoa(int(d/time.Minute)) + "m"
+	case d < 24*time.Hour:
+		return strconv.Itoa(int(d/time.Hour)) + "h"
+	case d < 30*24*time.Hour:
+		return strconv.Itoa(int(d/(24*time.Hour))) + "d"
+	case d < 365*24*time.Hour:
+		return strconv.Itoa(int(d/(30*24*time.Hour))) + "mo"
+	}
+	return strconv.Itoa(int(d/(365*24*time.Hour))) + "y"
+}
+
+func (t *table) add(item interface{}) error {
+	var decoded interface{}
+	row := make([]string, len(t.columns))
+	for i, column := range t.columns {
+		if def, ok := t.defs[column]; ok {
+			row[i] = def.value(item)
+			continue
+		}
+
+		// Not a named column, look up api field
+		if nil == decoded {
+			var err error
+			decoded, err = decodeItem(item)
+			if nil != err {
+				return err
+			}
+		}
+		row[i] = lookupColumn(decoded, strings.Split(column, "."))
+	}
+
+	// Cells are single line
+	for i := range row {
+		row[i] = strings.Join(strings.Fields(row[i]), " ")
+	}
+
+	t.rows = append(t.rows, row)
+	return nil
+}
+
+/// Column widths, shrinking flexible columns to fit the table width
+func (t *table) columnWidths() []int {
+	widths := make([]int, len(t.columns))
+	for i, column := range t.columns {
+		widths[i] = runewidth.StringWidth(t.header(column))
+	}
+	for _, row := range t.rows {
+		for i, cell := range row {
+			if n := runewidth.StringWidth(cell); n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+
+	if t.width <= 0 {
+		return widths
+	}
+
+	total := len(tableGap) * (len(widths) - 1)
+	for _, w := range widths {
+		total += w
+	}
+
+	// Take the overflow from the widest flexible column, one character at a time
+	for total > t.width {
+		widest := -1
+		for i, column := range t.columns {
+			if t.defs[column].flex && widths[i] > tableMinFlexWidth && (widest < 0 || widths[i] > widths[widest]) {
+				widest = i
+			}
+		}
+		if widest < 0 {
+			break
+		}
+		widths[widest]--
+		total--
+	}
+
+	return widths
+}
+
+func (t *table) header(column string) string {
+	if def, ok := t.defs[column]; ok && def.header != "" {
+		return def.header
+	}
+	return strings.ToUpper(column)
+}
+
+func (t *table) write(w io.Writer) error {
+	widths := t.columnWidths()
+	funcs := templateFuncs[t.colors]
+	colorize := func(name, s string) string {
+		if fun, ok := funcs[name].(func(string) string); ok {
+			return fun(s)
+		}
+		return s
+	}
+
+	line := func(cells []string, color func(i int, cell string) string) error {
+		var parts []string
+		for i, cell := range cells {
+			cell = truncate(cell, widths[i])
+			padding := strings.Repeat(" ", widths[i]-runewidth.StringWidth(cell))
+			if i == len(cells)-1 {
+				padding = ""
+			}
+			parts = append(parts, color(i, cell)+padding)
+		}
+		_, err := fmt.Fprintln(w, strings.TrimRight(strings.Join(parts, tableGap), " "))
+		return err
+	}
+
+	headers := make([]string, len(t.columns))
+	for i, column := range t.columns {
+		headers[i] = t.header(column)
+	}
+	err := line(headers, func(i int, cell string) string {
+		return colorize("bold", cell)
+	})
+	if nil != err {
+		return err
+	}
+
+	for _, row := range t.rows {
+		err = line(row, func(i int, cell string) string {
+			if def, ok := t.defs[t.columns[i]]; ok && nil != def.color {
+				return colorize(def.color(cell), cell)
+			}
+			return cell
+		})
+		if nil != err {
+			return err
+		}
+	}
+
+	t.rows = nil
+	return nil
+}
+
+/// Truncate to width terminal columns, marking truncation with an ellipsis
+func truncate(s string, width int) string {
+	if runewidth.StringWidth(s) <= width {
+		return s
+	}
+	if width <= 1 {
+		return runewidth.Truncate(s, width, "")
+	}
+	return runewidth.Truncate(s, width, "…")
+}
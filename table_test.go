@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestShortAge(t *testing.T) {
+	now := time.Date(2015, 12, 9, 12, 0, 0, 0, time.UTC)
+
+	for age, expected := range map[time.Duration]string{
+		30 * time.Second:     "now",
+		45 * time.Minute:     "45m",
+		3 * time.Hour:        "3h",
+		50 * time.Hour:       "2d",
+		100 * 24 * time.Hour: "3mo",
+		800 * 24 * time.Hour: "2y",
+	} {
+		if got := shortAge(now.Add(-age), now); got != expected {
+			t.Fatalf("Expected %s to be %s, got: %s\n", age, expected, got)
+		}
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	if got := truncate("merge request", 8); got != "merge r…" {
+		t.Fatal("Expected truncated string, got:", got)
+	}
+	if got := truncate("short", 8); got != "short" {
+		t.Fatal("Expected untouched string, got:", got)
+	}
+	if got := truncate("修正マージ", 7); got != "修正マ…" {
+		t.Fatal("Expected wide characters truncated to their width, got:", got)
+	}
+}
+
+func TestTableFitsWidth(t *testing.T) {
+	tbl := &table{
+		defs:    mergeRequestTableColumns,
+		columns: []string{"iid", "title", "milestone.title"},
+		width:   40,
+	}
+	tbl.add(mergeRequest{Iid: 7, Title: "A rather long merge request title", Milestone: &milestone{Title: "v1.0"}})
+	tbl.add(mergeRequest{Iid: 12, Title: "Short", Draft: true})
+
+	var out bytes.Buffer
+	err := tbl.write(&out)
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	expected := "IID  TITLE               MILESTONE.TITLE\n" +
+		"#7   A rather long mer…  v1.0\n" +
+		"#12  Draft: Short\n"
+	if out.String() != expected {
+		t.Fatalf("Expected:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
@@ -1,25 +1,127 @@
 package main
 
 import (
+	"fmt"
 	"github.com/andrew-d/go-termutil"
 	"github.com/fatih/color"
+	"github.com/mattn/go-runewidth"
 	"os"
 	"strconv"
+	"strings"
 	"text/template"
 	"time"
+	"unicode"
+	"unicode/utf8"
 )
 
+const SLUG_MAX_LENGTH int = 50
+
 const MergeRequestListTemplate string = `
+{{ with .ProjectPath }}{{ cyan . }}{{ end }}{{ blue "#" }}{{ itoa .Iid | yellow }} {{ .Title | green | bold }}{{ if .IsDraft }} {{ yellow "(draft)" }}{{ end }}
+{{ green .SourceBranch }} -> {{ red .TargetBranch }}{{ with .Author }} by {{ usernames . | blue }}{{ end }}{{ with .Labels }} {{ join . ", " | magenta }}{{ end }}{{ with .PipelineStatus }} pipeline {{ statusColor . }}{{ end }}
+
+{{ markdown .Description .WebUrl }}
+
+`
+
+const MergeRequestViewTemplate string = `{{ blue "!" }}{{ itoa .Iid | yellow }} {{ .Title | bold }}{{ if .IsDraft }} {{ yellow "(draft)" }}{{ end }}
+{{ statusColor .State }}, created {{ ago .CreatedAt }}{{ with .Author }} by {{ .Name }} {{ blue "@" }}{{ .Username | blue }}{{ end }}{{ with .MergedAt }}, merged {{ ago . }}{{ end }}{{ with .ClosedAt }}, closed {{ ago . }}{{ end }}
+
+{{ "Branches:" | bold }}   {{ green .SourceBranch }} -> {{ red .TargetBranch }}
+{{ with .AllAssignees }}{{ "Assignees:" | bold }}  {{ usernames . | blue }}
+{{ end }}{{ with .Reviewers }}{{ "Reviewers:" | bold }}  {{ usernames . | blue }}
+{{ end }}{{ with .Labels }}{{ "Labels:" | bold }}     {{ join . ", " | magenta }}
+{{ end }}{{ with .Milestone }}{{ "Milestone:" | bold }}  {{ .Title }}
+{{ end }}{{ with .HeadPipeline }}{{ "Pipeline:" | bold }}   #{{ itoa .Id }} {{ statusColor .Status }}
+{{ end }}{{ with .Approvals }}{{ "Approvals:" | bold }}  {{ len .ApprovedBy | itoa }} of {{ itoa .ApprovalsRequired }} required{{ if .Approved }} {{ green "(approved)" }}{{ end }}
+{{ end }}{{ "Merge:" | bold }}      {{ if .DetailedMergeStatus }}{{ .DetailedMergeStatus }}{{ else }}{{ .MergeStatus }}{{ end }}{{ if .HasConflicts }} {{ red "(has conflicts)" }}{{ end }}
+{{ with .Description }}
+{{ markdown . $.WebUrl }}
+{{ end }}{{ with .Activity }}
+{{ "Recent activity:" | bold }}
+{{ range . }}  {{ ago .CreatedAt | magenta }} {{ blue "@" }}{{ .Author.Username | blue }} {{ .Excerpt | truncate 72 }}
+{{ end }}{{ end }}
+{{ .WebUrl | cyan }}
+`
+
+const MergeRequestAcceptTemplate string = `
 {{ blue "#" }}{{ itoa .Iid | yellow }} {{ .Title | green | bold }}
 {{ green .SourceBranch }} -> {{ red .TargetBranch }}
+{{ with .HeadPipeline }}Pipeline:  #{{ itoa .Id }} {{ .Status }}
+{{ end }}{{ if .MergeStatus }}Status:    {{ .MergeStatus }}
+{{ end }}{{ with .Approvals }}Approvals: {{ len .ApprovedBy | itoa }} of {{ itoa .ApprovalsRequired }} given
+{{ end }}{{ with .Options }}{{ if .Squash }}Squash:    yes
+{{ end }}{{ if .MergeWhenPipelineSucceeds }}Merge:     when pipeline succeeds
+{{ end }}{{ if not .ShouldRemoveSourceBranch }}Branch:    keep
+{{ end }}{{ end }}
+`
+
+const MergeRequestApprovalsTemplate string = `
+{{ blue "#" }}{{ itoa .Iid | yellow }} {{ .Title | green | bold }}
+{{ with .Approvals }}{{ "Approvals:" | bold }} {{ len .ApprovedBy | itoa }} of {{ itoa .ApprovalsRequired }} required{{ if .Approved }} {{ green "(approved)" }}{{ end }}
+{{ range .ApprovedBy }}  {{ green "+" }} {{ .User.Name }} {{ blue "@" }}{{ .User.Username | blue }}
+{{ end }}{{ with .EligibleApprovers }}{{ "Eligible approvers:" | bold }}
+{{ range . }}    {{ .Name }} {{ blue "@" }}{{ .Username | blue }}
+{{ end }}{{ end }}{{ else }}Approvals are not available for this merge request
+{{ end }}
+`
+
+const MergeRequestDiscussionTemplate string = `
+{{ .ShortId | yellow }}{{ with .Position }} {{ .Location | cyan }}{{ end }}{{ if .Resolvable }}{{ if .Resolved }} {{ green "[resolved]" }}{{ else }} {{ red "[unresolved]" }}{{ end }}{{ end }}
+{{ range $i, $note := .Notes }}{{ if $i }}  {{ end }}{{ .Author.Name | bold }} {{ blue "@" }}{{ .Author.Username | blue }} {{ .CreatedAt | shortDate | magenta }}
+{{ if $i }}{{ markdown .Body | indent 4 }}{{ else }}{{ markdown .Body | indent 2 }}{{ end }}
+{{ end }}`
+
+const DiffLineTemplate string = `{{ .Gutter }} {{ if eq .Kind "+" }}{{ green .Text }}{{ else if eq .Kind "-" }}{{ red .Text }}{{ else if eq .Kind "@" }}{{ cyan .Text }}{{ else if eq .Kind "" }}{{ bold .Text }}{{ else }}{{ .Text }}{{ end }}
+`
+
+const MergeRequestCheckoutListTemplate string = `{{ blue "!" }}{{ itoa .Iid | yellow }} {{ green .Title }}
+`
+
+const MergeRequestPreviewTemplate string = `!{{ .Iid }} {{ .Title }}{{ if .IsDraft }} (draft){{ end }}
+{{ .SourceBranch }} -> {{ .TargetBranch }}{{ with .Author }} by @{{ .Username }}{{ end }}, updated {{ ago .UpdatedAt }}{{ with .PipelineStatus }}, pipeline {{ . }}{{ end }}
+{{ with .Labels }}{{ join . ", " }}
+{{ end }}
+{{ markdown .Description .WebUrl }}
+`
+
+const IssueListTemplate string = `
+{{ blue "#" }}{{ itoa .Iid | yellow }} {{ .Title | green | bold }}{{ if .Confidential }} {{ yellow "(confidential)" }}{{ end }}
+{{ statusColor .State }}{{ with .Author }} by {{ usernames . | blue }}{{ end }}{{ with .AllAssignees }}, assigned to {{ usernames . | blue }}{{ end }}{{ with .Labels }} {{ join . ", " | magenta }}{{ end }}
 
-{{ .Description }}
+{{ markdown .Description .WebUrl }}
 
 `
 
-const MergeRequestCheckoutListTemplate string = `{{ green .Title }}
+const IssueViewTemplate string = `{{ blue "#" }}{{ itoa .Iid | yellow }} {{ .Title | bold }}{{ if .Confidential }} {{ yellow "(confidential)" }}{{ end }}
+{{ statusColor .State }}, created {{ ago .CreatedAt }}{{ with .Author }} by {{ .Name }} {{ blue "@" }}{{ .Username | blue }}{{ end }}{{ with .ClosedAt }}, closed {{ ago . }}{{ end }}
+
+{{ with .AllAssignees }}{{ "Assignees:" | bold }}  {{ usernames . | blue }}
+{{ end }}{{ with .Labels }}{{ "Labels:" | bold }}     {{ join . ", " | magenta }}
+{{ end }}{{ with .Milestone }}{{ "Milestone:" | bold }}  {{ .Title }}
+{{ end }}{{ with .DueDate }}{{ "Due:" | bold }}        {{ . }}
+{{ end }}{{ with .Description }}
+{{ markdown . $.WebUrl }}
+{{ end }}{{ with .Activity }}
+{{ "Recent activity:" | bold }}
+{{ range . }}  {{ ago .CreatedAt | magenta }} {{ blue "@" }}{{ .Author.Username | blue }} {{ .Excerpt | truncate 72 }}
+{{ end }}{{ end }}
+{{ .WebUrl | cyan }}
+`
+
+const PipelineStatusTemplate string = `{{ "Pipeline" | bold }} {{ blue "#" }}{{ itoa .Id | yellow }} {{ statusColor .Status }} on {{ green .Ref }} {{ printf "%.8s" .Sha }}{{ with .User }} by {{ usernames . | blue }}{{ end }}, created {{ ago .CreatedAt }}{{ with .Duration }}, took {{ duration . }}{{ end }}
+{{ range .Stages }}
+{{ .Name | bold }} {{ statusColor .Status }}
+{{ range .Jobs }}  {{ .Status | pad 9 | statusColor }} {{ .Name }}{{ with .Duration }} {{ duration . | faint }}{{ end }}{{ if and .AllowFailure (eq .Status "failed") }} {{ yellow "(allowed to fail)" }}{{ end }}
+{{ end }}{{ end }}
+{{ .WebUrl | cyan }}
+`
+
+const PipelineListTemplate string = `{{ blue "#" }}{{ itoa .Id | yellow }} {{ .Status | pad 9 | statusColor }} {{ green .Ref }} {{ printf "%.8s" .Sha }}{{ with .User }} {{ usernames . | blue }}{{ end }} {{ ago .CreatedAt | magenta }}
 `
 
+const IssueBranchTemplate string = `{{ .Iid }}-{{ slug .Title }}`
+
 const FeedTitleTemplate string = `
 {{ .Title | bold  }}
 `
@@ -50,6 +152,15 @@ func init() {
 		"bold": func(input string) string {
 			return color.New(color.Bold).SprintFunc()(input)
 		},
+		"italic": func(input string) string {
+			return color.New(color.Italic).SprintFunc()(input)
+		},
+		"underline": func(input string) string {
+			return color.New(color.Underline).SprintFunc()(input)
+		},
+		"faint": func(input string) string {
+			return color.New(color.Faint).SprintFunc()(input)
+		},
 	}
 	for c, fun := range colorFuncs {
 		colorFuncMap[c] = func(finner formatFunc) func(string) string {
@@ -70,18 +181,180 @@ func init() {
 	}
 	templateFuncs[false] = monochromeFuncs
 
+	// Color by name, eg. {{ color "red" .Title }}, or only if cond is true
+	colorByName := func(name, input string) string {
+		if fun, ok := colorFuncMap[name].(func(string) string); ok {
+			return fun(input)
+		}
+		return input
+	}
+	colorFuncMap["color"] = colorByName
+	colorFuncMap["colorIf"] = func(cond bool, name, input string) string {
+		if cond {
+			return colorByName(name, input)
+		}
+		return input
+	}
+	colorFuncMap["statusColor"] = func(status string) string {
+		// Status may be padded: {{ .Status | pad 9 | statusColor }}
+		return colorByName(statusColor(strings.TrimSpace(status)), status)
+	}
+	colorFuncMap["link"] = hyperlink
+	colorFuncMap["markdown"] = func(input string, webUrl ...string) string {
+		return renderMarkdown(input, true, webUrl...)
+	}
+	monochromeFuncs["color"] = func(name, input string) string {
+		return input
+	}
+	monochromeFuncs["colorIf"] = func(cond bool, name, input string) string {
+		return input
+	}
+	monochromeFuncs["statusColor"] = stringIdentity
+	monochromeFuncs["link"] = func(url, text string) string {
+		return text
+	}
+	monochromeFuncs["markdown"] = func(input string, webUrl ...string) string {
+		return renderMarkdown(input, false, webUrl...)
+	}
+
 	// Shared functions
 	for _, b := range []bool{true, false} {
 		templateFuncs[b]["itoa"] = strconv.Itoa
-		templateFuncs[b]["shortDate"] = func(t time.Time) string {
-			return t.Format("2006-01-02 15:04")
+		templateFuncs[b]["shortDate"] = shortDate
+		templateFuncs[b]["ago"] = ago
+		templateFuncs[b]["usernames"] = usernames
+		templateFuncs[b]["join"] = strings.Join
+		templateFuncs[b]["upper"] = strings.ToUpper
+		templateFuncs[b]["lower"] = strings.ToLower
+		templateFuncs[b]["truncate"] = func(width int, input string) string {
+			return truncate(input, width)
+		}
+		templateFuncs[b]["pad"] = pad
+		templateFuncs[b]["slug"] = slug
+		templateFuncs[b]["duration"] = duration
+		templateFuncs[b]["padLeft"] = padLeft
+		templateFuncs[b]["indent"] = func(spaces int, input string) string {
+			prefix := strings.Repeat(" ", spaces)
+			return prefix + strings.Replace(input, "\n", "\n"+prefix, -1)
+		}
+	}
+}
+
+/// Get time of time.Time, or *time.Time which may be nil
+func timeValue(t interface{}) (time.Time, bool) {
+	switch t := t.(type) {
+	case time.Time:
+		return t, true
+	case *time.Time:
+		if t != nil {
+			return *t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+/// Format time.Time, or *time.Time which may be nil, eg. "merged_at"
+func shortDate(t interface{}) string {
+	if t, ok := timeValue(t); ok {
+		return t.Format("2006-01-02 15:04")
+	}
+	return ""
+}
+
+/// Format time relative to now, eg. "3h ago"
+func ago(t interface{}) string {
+	value, ok := timeValue(t)
+	if !ok || value.IsZero() {
+		return ""
+	}
+	age := shortAge(value, time.Now())
+	if age == "now" {
+		return "just now"
+	}
+	return age + " ago"
+}
+
+/// Format seconds, eg. of pipelines and jobs: "45s", "3m 05s", "1h 02m"
+func duration(seconds float64) string {
+	d := time.Duration(seconds) * time.Second
+	switch {
+	case d < time.Minute:
+		return strconv.Itoa(int(d/time.Second)) + "s"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm %02ds", int(d/time.Minute), int(d%time.Minute/time.Second))
+	}
+	return fmt.Sprintf("%dh %02dm", int(d/time.Hour), int(d%time.Hour/time.Minute))
+}
+
+/// Lowercase words joined by dashes, eg. for branch names: {{ slug .Title }}
+func slug(input string) string {
+	words := strings.FieldsFunc(strings.ToLower(input), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	// Keep branch names manageable, cutting between words, or a first word too long by itself
+	slugged := ""
+	for _, word := range words {
+		if slugged != "" && len(slugged)+1+len(word) > SLUG_MAX_LENGTH {
+			break
+		}
+		for len(word) > SLUG_MAX_LENGTH {
+			_, size := utf8.DecodeLastRuneInString(word)
+			word = word[:len(word)-size]
+		}
+		if slugged != "" {
+			slugged += "-"
+		}
+		slugged += word
+	}
+	return slugged
+}
+
+/// Pad to width with spaces on the right, apply before colors: {{ .Title | pad 30 | green }}
+func pad(width int, input string) string {
+	if n := runewidth.StringWidth(input); n < width {
+		return input + strings.Repeat(" ", width-n)
+	}
+	return input
+}
+
+/// Pad to width with spaces on the left, eg. for numbers
+func padLeft(width int, input string) string {
+	if n := runewidth.StringWidth(input); n < width {
+		return strings.Repeat(" ", width-n) + input
+	}
+	return input
+}
+
+/// Terminal hyperlink (OSC 8), terminals without support show the text only
+func hyperlink(url, text string) string {
+	if url == "" {
+		return text
+	}
+	return "\x1b]8;;" + url + "\x1b\\" + text + "\x1b]8;;\x1b\\"
+}
+
+/// Format users for templates, eg. "@alice, @bob"
+func usernames(users interface{}) string {
+	var names []string
+	switch users := users.(type) {
+	case []user:
+		for _, u := range users {
+			names = append(names, "@"+u.Username)
+		}
+	case *user:
+		if users != nil {
+			names = append(names, "@"+users.Username)
 		}
+	case user:
+		names = append(names, "@"+users.Username)
 	}
+	return strings.Join(names, ", ")
 }
 
 /// Determine from tty output, whether we should do colors
 func doColors(output *os.File) bool {
-	return termutil.Isatty(output.Fd())
+	return termutil.Isatty(underlyingTerminal(output).Fd())
 }
 
 /// Get new template for colored output
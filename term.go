@@ -0,0 +1,28 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"syscall"
+	"unsafe"
+)
+
+/// Width of the terminal on output, or behind the pager, $COLUMNS when not a terminal, 0 if unknown
+func terminalWidth(output *os.File) int {
+	var size struct {
+		rows, cols, xpixel, ypixel uint16
+	}
+	output = underlyingTerminal(output)
+	_, _, errno := syscall.Syscall(
+		syscall.SYS_IOCTL,
+		output.Fd(),
+		uintptr(syscall.TIOCGWINSZ),
+		uintptr(unsafe.Pointer(&size)),
+	)
+	if errno == 0 && size.cols > 0 {
+		return int(size.cols)
+	}
+
+	width, _ := strconv.Atoi(os.Getenv("COLUMNS"))
+	return width
+}
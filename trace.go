@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/codegangsta/cli"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/// Collapsible section markers of job logs, eg. "section_start:1560896352:build_script[collapsed=true]\r\x1b[0K"
+var traceSectionMarker = regexp.MustCompile("section_(start|end):[0-9]+:[^\r\n\\[]+(\\[[^\\]]*\\])?\r(\x1b\\[0K)?")
+
+/// Terminal control sequences of job logs: colors, and erasing or moving on the line
+var traceControlSequence = regexp.MustCompile("\x1b\\[[0-9;]*[A-Za-z]")
+
+/// Renders job logs line by line as they come in, dropping section markers and overwritten progress
+type traceRenderer struct {
+	out     io.Writer
+	colors  bool
+	partial []byte
+}
+
+func (t *traceRenderer) Write(p []byte) (int, error) {
+	t.partial = append(t.partial, p...)
+	for {
+		i := bytes.IndexByte(t.partial, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		err := t.line(string(t.partial[:i]))
+		t.partial = t.partial[i+1:]
+		if nil != err {
+			return len(p), err
+		}
+	}
+}
+
+/// Render the last line, which lacks a newline
+func (t *traceRenderer) flush() error {
+	if len(t.partial) == 0 {
+		return nil
+	}
+	line := string(t.partial)
+	t.partial = nil
+	return t.line(line)
+}
+
+func (t *traceRenderer) line(line string) error {
+	line = strings.TrimSuffix(line, "\r")
+	marked := traceSectionMarker.MatchString(line)
+	line = traceSectionMarker.ReplaceAllString(line, "")
+
+	// Progress output overwrites the line after carriage returns, keep what was left visible
+	segments := strings.Split(line, "\r")
+	line = segments[len(segments)-1]
+	for i := len(segments) - 1; i >= 0 && traceControlSequence.ReplaceAllString(line, "") == ""; i-- {
+		line = segments[i]
+	}
+
+	line = traceControlSequence.ReplaceAllStringFunc(line, func(sequence string) string {
+		if t.colors && strings.HasSuffix(sequence, "m") {
+			return sequence
+		}
+		return ""
+	})
+
+	// Lines of only a marker, eg. section ends
+	if marked && strings.TrimSpace(traceControlSequence.ReplaceAllString(line, "")) == "" {
+		return nil
+	}
+
+	_, err := fmt.Fprintln(t.out, line)
+	return err
+}
+
+/// Print the log of a job, following it while it runs
+func traceJob(c *cli.Context) {
+	server := needGitlab(c)
+	server.token = needToken(c)
+	projectId := needRemoteUrl(c).path
+
+	j, err := jobFromContext(c, server, projectId, "")
+	if err == ErrNoSelection {
+		return
+	}
+	if nil != err {
+		log.Fatal(err)
+	}
+	log.Printf("Log of job #%d %s/%s (%s): %s\n", j.Id, j.Stage, j.Name, j.Status, j.WebUrl)
+
+	var save *os.File
+	if file := c.String("save"); file != "" {
+		save, err = os.Create(file)
+		if nil != err {
+			log.Fatal(err)
+		}
+		defer save.Close()
+	}
+
+	out := &traceRenderer{out: os.Stdout, colors: doColors(os.Stdout)}
+	interval := watchInterval(c)
+	var offset int64
+	for {
+		chunk, err := server.getJobTrace(projectId, j.Id, offset)
+		if nil != err {
+			log.Fatal(err)
+		}
+		offset += int64(len(chunk))
+
+		if nil != save {
+			_, err = save.Write(chunk)
+			if nil != err {
+				log.Fatal(err)
+			}
+		}
+		_, err = out.Write(chunk)
+		if nil != err {
+			log.Fatal(err)
+		}
+
+		if pipelineFinished(j.Status) || c.Bool("no-follow") {
+			break
+		}
+		if len(chunk) == 0 {
+			time.Sleep(interval)
+		}
+
+		// The log may grow until the job is finished, get it once more after
+		j, err = server.getJob(projectId, j.Id)
+		if nil != err {
+			log.Fatal(err)
+		}
+	}
+
+	err = out.flush()
+	if nil != err {
+		log.Fatal(err)
+	}
+	if nil != save {
+		log.Println("Saved log to:", save.Name())
+	}
+
+	if j.Status == PIPELINE_STATUS_FAILED && !j.AllowFailure {
+		os.Exit(WATCH_EXIT_FAILED)
+	}
+}
+
+/// Job by ID, or by name or picked from the jobs of the latest pipeline of the current branch, or --pipeline.
+/// Only jobs of the given status are picked from, if any
+func jobFromContext(c *cli.Context, server gitlab, projectId string, status string) (*job, error) {
+	arg := c.Args().First()
+	if jobId, err := strconv.Atoi(arg); nil == err {
+		return server.getJob(projectId, jobId)
+	}
+
+	var p *pipeline
+	var err error
+	if pipelineId := c.Int("pipeline"); pipelineId != 0 {
+		p, err = server.getPipeline(projectId, pipelineId)
+	} else {
+		var branch string
+		branch, err = needGitDir(c).getCurrentBranch()
+		if nil != err {
+			return nil, err
+		}
+		p, err = server.getLatestPipeline(projectId, branch)
+	}
+	if nil != err {
+		return nil, err
+	}
+
+	if arg != "" {
+		return server.findJob(projectId, p.Id, arg)
+	}
+
+	jobs, err := server.getPipelineJobs(projectId, p.Id)
+	if nil != err {
+		return nil, err
+	}
+	var ordered []job
+	for _, stage := range pipelineStages(jobs) {
+		for _, j := range stage.Jobs {
+			if status == "" || j.Status == status {
+				ordered = append(ordered, j)
+			}
+		}
+	}
+	if len(ordered) == 0 {
+		return nil, fmt.Errorf("No %s in pipeline #%d\n", strings.TrimSpace(status+" jobs"), p.Id)
+	}
+
+	items := make([]pickerItem, len(ordered))
+	for i, j := range ordered {
+		items[i] = pickerItem{
+			label:   fmt.Sprintf("%-9s %s/%s", j.Status, j.Stage, j.Name),
+			preview: fmt.Sprintf("Job #%d %s\nStage: %s\nPipeline: #%d %s\n\n%s", j.Id, j.Status, j.Stage, p.Id, p.Ref, j.WebUrl),
+		}
+	}
+	i, err := pick("Job", items)
+	if nil != err {
+		return nil, err
+	}
+	return &ordered[i], nil
+}
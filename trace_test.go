@@ -0,0 +1,33 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestTraceRenderer(t *testing.T) {
+	var out bytes.Buffer
+	r := &traceRenderer{out: &out}
+	for _, chunk := range []string{
+		"\x1b[0KRunning with gitlab-runner\n",
+		"section_start:1560896352:build_script[collapsed=true]\r\x1b[0K\x1b[32;1m$ make\x1b[0;m\n",
+		"Downloading  10%\rDownloading 100%\r\n",
+		"ok  \t./...\nsection_end:1560896353:build_scri",
+		"pt\r\x1b[0K\nJob succeeded",
+	} {
+		r.Write([]byte(chunk))
+	}
+	r.flush()
+
+	expected := "Running with gitlab-runner\n$ make\nDownloading 100%\nok  \t./...\nJob succeeded\n"
+	if out.String() != expected {
+		t.Fatalf("Expected %q, got: %q\n", expected, out.String())
+	}
+
+	out.Reset()
+	r = &traceRenderer{out: &out, colors: true}
+	r.Write([]byte("\x1b[0K\x1b[31;1mJob failed\x1b[0;m\n"))
+	if out.String() != "\x1b[31;1mJob failed\x1b[0;m\n" {
+		t.Fatalf("Expected colors to be kept, got: %q\n", out.String())
+	}
+}
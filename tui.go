@@ -0,0 +1,318 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/andrew-d/go-termutil"
+	"github.com/codegangsta/cli"
+	"github.com/nsf/termbox-go"
+	"log"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const DASHBOARD_HELP string = "enter/v view  d diff  c checkout  a approve  m comment  A accept  o open  r refresh  q quit"
+
+/// Pane of the dashboard, with merge requests or other items like pipelines
+type dashboardPane struct {
+	title    string
+	items    []pickerItem
+	requests []mergeRequest
+	urls     []string
+	cursor   int
+	offset   int
+}
+
+type dashboard struct {
+	c         *cli.Context
+	server    gitlab
+	projectId string
+	panes     []*dashboardPane
+	current   int
+	status    string
+}
+
+/// Full screen dashboard of merge requests, pipelines and activity of the project
+func showDashboard(c *cli.Context) {
+	if !termutil.Isatty(os.Stdin.Fd()) || !termutil.Isatty(os.Stdout.Fd()) {
+		log.Fatal("The dashboard needs a terminal")
+	}
+
+	server := needGitlab(c)
+	server.token = needToken(c)
+	d := &dashboard{
+		c:         c,
+		server:    server,
+		projectId: needRemoteUrl(c).path,
+		panes: []*dashboardPane{
+			&dashboardPane{title: "Mine"},
+			&dashboardPane{title: "Review"},
+			&dashboardPane{title: "Pipelines"},
+			&dashboardPane{title: "Activity"},
+		},
+	}
+
+	err := termbox.Init()
+	if nil != err {
+		log.Fatal(err)
+	}
+	defer termbox.Close()
+
+	d.status = "Loading..."
+	d.draw()
+	d.load()
+
+	for {
+		d.draw()
+		ev := termbox.PollEvent()
+		if ev.Type == termbox.EventError {
+			d.status = ev.Err.Error()
+			continue
+		}
+		if ev.Type == termbox.EventKey && !d.handle(ev) {
+			return
+		}
+	}
+}
+
+/// Load all panes, keeping the selection where possible
+func (d *dashboard) load() {
+	d.status = ""
+	me, err := d.server.getCurrentUser()
+	if nil != err {
+		d.fail(err)
+		return
+	}
+
+	for i, filter := range []string{"author_id", "reviewer_id"} {
+		requests, err := d.server.queryMergeRequests(d.projectId, url.Values{filter: {strconv.Itoa(me.Id)}})
+		if nil != err {
+			d.fail(err)
+			return
+		}
+		items, err := mergeRequestPickerItems(MergeRequestCheckoutListTemplate, requests)
+		if nil != err {
+			d.fail(err)
+			return
+		}
+		d.panes[i].items = items
+		d.panes[i].requests = requests
+		d.panes[i].urls = nil
+		for _, request := range requests {
+			d.panes[i].urls = append(d.panes[i].urls, request.WebUrl)
+		}
+	}
+
+	pipelines, err := d.server.getPipelines(d.projectId, url.Values{"per_page": {"20"}})
+	if nil != err {
+		d.fail(err)
+		return
+	}
+	d.panes[2].items, d.panes[2].urls = nil, nil
+	for _, p := range pipelines {
+		d.panes[2].items = append(d.panes[2].items, pickerItem{
+			label:   fmt.Sprintf("#%-7d %-9s %s", p.Id, p.Status, p.Ref),
+			preview: fmt.Sprintf("Pipeline #%d %s\nRef: %s\nCommit: %s\n\n%s", p.Id, p.Status, p.Ref, p.Sha, p.WebUrl),
+		})
+		d.panes[2].urls = append(d.panes[2].urls, p.WebUrl)
+	}
+
+	activity, err := d.server.getActivityFeed(d.server.getProjectFeedUrl(d.projectId))
+	if nil != err {
+		d.fail(err)
+		return
+	}
+	d.panes[3].items, d.panes[3].urls = nil, nil
+	for _, entry := range activity.Entries {
+		d.panes[3].items = append(d.panes[3].items, pickerItem{
+			label:   shortDate(entry.Updated) + " " + entry.Title,
+			preview: entry.Title,
+		})
+		d.panes[3].urls = append(d.panes[3].urls, entry.Link.Href)
+	}
+
+	for _, pane := range d.panes {
+		pane.move(0)
+	}
+}
+
+func (d *dashboard) fail(err error) {
+	d.status = strings.TrimSpace(err.Error())
+}
+
+/// Move the cursor by delta, staying within the items
+func (p *dashboardPane) move(delta int) {
+	p.cursor += delta
+	if p.cursor >= len(p.items) {
+		p.cursor = len(p.items) - 1
+	}
+	if p.cursor < 0 {
+		p.cursor = 0
+	}
+}
+
+/// Handle a key, returns false to quit
+func (d *dashboard) handle(ev termbox.Event) bool {
+	pane := d.panes[d.current]
+	_, height := termbox.Size()
+	d.status = ""
+
+	switch ev.Key {
+	case termbox.KeyEsc, termbox.KeyCtrlC:
+		return false
+	case termbox.KeyTab, termbox.KeyArrowRight:
+		d.current = (d.current + 1) % len(d.panes)
+	case termbox.KeyArrowLeft:
+		d.current = (d.current + len(d.panes) - 1) % len(d.panes)
+	case termbox.KeyArrowDown, termbox.KeyCtrlN:
+		pane.move(1)
+	case termbox.KeyArrowUp, termbox.KeyCtrlP:
+		pane.move(-1)
+	case termbox.KeyPgdn:
+		pane.move(height / 2)
+	case termbox.KeyPgup:
+		pane.move(-height / 2)
+	case termbox.KeyEnter:
+		d.withMergeRequest(viewMergeRequest)
+	}
+
+	switch ev.Ch {
+	case 'q':
+		return false
+	case '1', '2', '3', '4':
+		d.current = int(ev.Ch - '1')
+	case 'l':
+		d.current = (d.current + 1) % len(d.panes)
+	case 'h':
+		d.current = (d.current + len(d.panes) - 1) % len(d.panes)
+	case 'j':
+		pane.move(1)
+	case 'k':
+		pane.move(-1)
+	case 'g':
+		pane.move(-len(pane.items))
+	case 'G':
+		pane.move(len(pane.items))
+	case 'r':
+		d.status = "Loading..."
+		d.draw()
+		d.load()
+	case 'o':
+		if len(pane.urls) > pane.cursor && pane.urls[pane.cursor] != "" {
+			d.browse(pane.urls[pane.cursor])
+		}
+	case 'v':
+		d.withMergeRequest(viewMergeRequest)
+	case 'd':
+		d.withMergeRequest(diffMergeRequest)
+	case 'c':
+		d.withMergeRequest(checkoutMergeRequest)
+	case 'a':
+		d.withMergeRequest(approveMergeRequest)
+	case 'm':
+		d.withMergeRequest(commentOnMergeRequest)
+	case 'A':
+		d.withMergeRequest(acceptMergeRequest)
+	}
+
+	return true
+}
+
+/// Open url in the browser outside of the dashboard, a text browser may take over the terminal
+func (d *dashboard) browse(url string) {
+	termbox.Close()
+	browseErr := browsePlatform(url)
+	err := termbox.Init()
+	if nil != err {
+		log.Fatal(err)
+	}
+	if nil != browseErr {
+		d.status = "Unable to open browser: " + strings.TrimSpace(browseErr.Error())
+	}
+}
+
+/// Run an action of the mr commands on the selected merge request, outside of the dashboard
+func (d *dashboard) withMergeRequest(action func(*cli.Context, gitlab, string, mergeRequest) error) {
+	pane := d.panes[d.current]
+	if len(pane.requests) == 0 {
+		d.status = "Select a merge request in one of the first two panes"
+		return
+	}
+	req := pane.requests[pane.cursor]
+
+	termbox.Close()
+	err := action(d.c, d.server, d.projectId, req)
+	if nil != err && !isBrokenPipe(err) {
+		fmt.Fprintln(os.Stderr, strings.TrimSpace(err.Error()))
+	}
+	fmt.Fprint(os.Stderr, "Press enter to return to the dashboard")
+	bufio.NewReader(os.Stdin).ReadString('\n')
+
+	err = termbox.Init()
+	if nil != err {
+		log.Fatal(err)
+	}
+	d.status = "Loading..."
+	d.draw()
+	d.load()
+}
+
+func (d *dashboard) draw() {
+	termbox.Clear(termbox.ColorDefault, termbox.ColorDefault)
+	width, height := termbox.Size()
+
+	// Tabs
+	x := drawText(0, 0, width, "lab "+d.projectId+" ", termbox.ColorBlue|termbox.AttrBold, termbox.ColorDefault)
+	for i, pane := range d.panes {
+		fg := termbox.ColorDefault
+		if i == d.current {
+			fg |= termbox.AttrReverse
+		}
+		x = drawText(x, 0, width, fmt.Sprintf(" %d %s (%d) ", i+1, pane.title, len(pane.items)), fg, termbox.ColorDefault)
+		x = drawText(x, 0, width, " ", termbox.ColorDefault, termbox.ColorDefault)
+	}
+
+	// List in the upper half, preview of the selected item below
+	pane := d.panes[d.current]
+	listHeight := (height - 3) / 2
+	if pane.cursor < pane.offset {
+		pane.offset = pane.cursor
+	}
+	if pane.cursor >= pane.offset+listHeight {
+		pane.offset = pane.cursor - listHeight + 1
+	}
+	if len(pane.items) == 0 {
+		drawText(2, 1, width, "Nothing here", termbox.ColorYellow, termbox.ColorDefault)
+	}
+	for row := 0; row < listHeight && pane.offset+row < len(pane.items); row++ {
+		fg := termbox.ColorDefault
+		line := "  " + pane.items[pane.offset+row].label
+		if pane.offset+row == pane.cursor {
+			fg |= termbox.AttrReverse
+			line = "> " + pane.items[pane.offset+row].label + strings.Repeat(" ", width)
+		}
+		drawText(0, 1+row, width, line, fg, termbox.ColorDefault)
+	}
+
+	top := 1 + listHeight
+	drawText(0, top, width, strings.Repeat("─", width), termbox.ColorBlue, termbox.ColorDefault)
+	if len(pane.items) > 0 {
+		for i, line := range wrapText(pane.items[pane.cursor].preview, width) {
+			if top+1+i >= height-1 {
+				break
+			}
+			drawText(0, top+1+i, width, line, termbox.ColorDefault, termbox.ColorDefault)
+		}
+	}
+
+	if d.status != "" {
+		drawText(0, height-1, width, d.status, termbox.ColorRed, termbox.ColorDefault)
+	} else {
+		drawText(0, height-1, width, DASHBOARD_HELP, termbox.ColorBlue, termbox.ColorDefault)
+	}
+
+	termbox.HideCursor()
+	termbox.Flush()
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"github.com/codegangsta/cli"
+)
+
+/// Merge request with its latest notes, as shown by mr view
+type mergeRequestDetails struct {
+	mergeRequest
+	Activity []note `json:"activity"`
+}
+
+/// Show a merge request in detail, or open it in the browser with --web
+func viewMergeRequest(c *cli.Context, server gitlab, projectId string, req mergeRequest) error {
+	if c.Bool("web") {
+		browse(server.getMergeRequestUrl(projectId, req.Iid))
+		return nil
+	}
+	if formatHelp(c, MergeRequestViewTemplate, mergeRequestDetails{}) {
+		return nil
+	}
+
+	// Lists lack eg. the head pipeline and the detailed merge status
+	full, err := server.getMergeRequest(projectId, req.Iid)
+	if nil != err {
+		return err
+	}
+	details := mergeRequestDetails{mergeRequest: *full}
+	details.ProjectPath = req.ProjectPath
+
+	details.Approvals, err = server.getMergeRequestApprovals(projectId, req.Iid)
+	if nil != err {
+		return err
+	}
+
+	if count := c.Int("activity"); count > 0 {
+		discussions, err := server.getMergeRequestDiscussions(projectId, req.Iid)
+		if nil != err {
+			return err
+		}
+		details.Activity = recentNotes(discussions, count)
+	}
+
+	r, err := newRenderer(c, "merge-request-view", MergeRequestViewTemplate, mergeRequestColumns, false)
+	if nil != err {
+		return err
+	}
+
+	defer startPager(c)()
+	err = r.render(details)
+	if nil != err {
+		return err
+	}
+	return r.flush()
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/codegangsta/cli"
+	"github.com/fatih/color"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+const WATCH_MAX_INTERVAL time.Duration = 30 * time.Second
+const WATCH_MAX_ERRORS int = 5
+
+/// Exit codes of ci watch, by the status the pipeline finished with, or an error watching it
+const (
+	WATCH_EXIT_SUCCESS  int = 0
+	WATCH_EXIT_FAILED   int = 1
+	WATCH_EXIT_CANCELED int = 2
+	WATCH_EXIT_MANUAL   int = 3
+	WATCH_EXIT_ERROR    int = 4
+)
+
+/// Follow a pipeline until it finishes, redrawing its jobs in place on a terminal, exits by its status
+func watchPipeline(c *cli.Context) {
+	if formatHelp(c, PipelineStatusTemplate, pipelineDetails{}) {
+		return
+	}
+	fatalExitCode = WATCH_EXIT_ERROR
+
+	server := needGitlab(c)
+	server.token = needToken(c)
+	projectId := needRemoteUrl(c).path
+
+	p, err := waitForPipeline(c, server, projectId)
+	if nil != err {
+		fatal(err)
+	}
+
+	format, err := resolveFormat(c, PipelineStatusTemplate)
+	if nil != err {
+		fatal(err)
+	}
+	live := doColors(os.Stdout)
+	tmpl, err := newTemplate("pipeline-watch", format, live)
+	if nil != err {
+		fatal(err)
+	}
+
+	initial := watchInterval(c)
+	interval := initial
+	previous := make(map[int]string)
+	rows := 0
+	failures := 0
+	for {
+		details, err := server.getPipelineDetails(projectId, p.Id)
+		if nil != err {
+			// Keep watching through hiccups of the server or network
+			failures++
+			if failures >= WATCH_MAX_ERRORS {
+				fatal(err)
+			}
+			log.Println(strings.TrimSpace(err.Error()))
+			rows = 0
+			interval = nextWatchInterval(interval, initial, false)
+			time.Sleep(interval)
+			continue
+		}
+		failures = 0
+
+		changed := false
+		for _, stage := range details.Stages {
+			for _, j := range stage.Jobs {
+				if previous[j.Id] != j.Status {
+					changed = true
+					if !live {
+						fmt.Printf("%s %s/%s: %s\n", time.Now().Format("15:04:05"), j.Stage, j.Name, j.Status)
+					}
+					previous[j.Id] = j.Status
+				}
+			}
+		}
+
+		finished := pipelineFinished(details.Status)
+		if live {
+			var out bytes.Buffer
+			err = tmpl.Execute(&out, *details)
+			if nil != err {
+				fatal(err)
+			}
+			if !bytes.HasSuffix(out.Bytes(), []byte("\n")) {
+				out.WriteString("\n")
+			}
+			if !finished {
+				faint := color.New(color.Faint).SprintfFunc()
+				fmt.Fprintln(&out, faint("Updated %s, watching every %s, ctrl-c to stop", time.Now().Format("15:04:05"), interval))
+			}
+
+			// Move up to the previous drawing and clear it
+			if rows > 0 {
+				fmt.Printf("\x1b[%dA\x1b[J", rows)
+			}
+			os.Stdout.Write(out.Bytes())
+			rows = terminalRows(out.String(), terminalWidth(os.Stdout))
+		}
+
+		if finished {
+			if !live {
+				fmt.Printf("Pipeline #%d %s: %s\n", details.Id, details.Status, details.WebUrl)
+			}
+			if !c.Bool("no-bell") && doColors(os.Stderr) {
+				fmt.Fprint(os.Stderr, "\a")
+			}
+			os.Exit(pipelineExitCode(details.Status))
+		}
+
+		interval = nextWatchInterval(interval, initial, changed)
+		time.Sleep(interval)
+	}
+}
+
+/// Pipeline given by flags, or the latest of the current branch, waiting for one of its HEAD after a push
+func waitForPipeline(c *cli.Context, server gitlab, projectId string) (*pipeline, error) {
+	if c.Args().First() != "" {
+		return pipelineFromContext(c, server, projectId)
+	}
+
+	gitDir := needGitDir(c)
+	branch, err := gitDir.getCurrentBranch()
+	if nil != err {
+		return nil, err
+	}
+	head, err := gitDir.revParse("HEAD")
+	if nil != err {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(time.Duration(c.Int("wait")) * time.Second)
+	initial := watchInterval(c)
+	interval := initial
+	waiting := false
+	for {
+		p, err := server.getLatestPipeline(projectId, branch)
+		if nil == err && p.Sha == head {
+			return p, nil
+		}
+		if time.Now().After(deadline) {
+			if nil == err {
+				log.Printf("No pipeline for HEAD %.8s yet, watching #%d of %.8s\n", head, p.Id, p.Sha)
+				return p, nil
+			}
+			return nil, err
+		}
+
+		if !waiting {
+			log.Printf("Waiting for a pipeline of %s at %.8s\n", branch, head)
+			waiting = true
+		}
+		interval = nextWatchInterval(interval, initial, false)
+		time.Sleep(interval)
+	}
+}
+
+/// Seconds of --interval, at least one
+func watchInterval(c *cli.Context) time.Duration {
+	if seconds := c.Int("interval"); seconds > 1 {
+		return time.Duration(seconds) * time.Second
+	}
+	return time.Second
+}
+
+/// Poll again soon after changes, backing off up to WATCH_MAX_INTERVAL while nothing changes
+func nextWatchInterval(current, initial time.Duration, changed bool) time.Duration {
+	if changed || current < initial {
+		return initial
+	}
+	next := current * 3 / 2
+	if next > WATCH_MAX_INTERVAL {
+		return WATCH_MAX_INTERVAL
+	}
+	return next
+}
+
+/// Whether the pipeline is done, or blocked on manual jobs
+func pipelineFinished(status string) bool {
+	switch status {
+	case "success", PIPELINE_STATUS_FAILED, "canceled", "skipped", "manual":
+		return true
+	}
+	return false
+}
+
+/// Exit code of ci watch for the status of the finished pipeline
+func pipelineExitCode(status string) int {
+	switch status {
+	case "success":
+		return WATCH_EXIT_SUCCESS
+	case PIPELINE_STATUS_FAILED:
+		return WATCH_EXIT_FAILED
+	case "manual":
+		return WATCH_EXIT_MANUAL
+	}
+	return WATCH_EXIT_CANCELED
+}
+
+/// Rows text takes on a terminal of the given width, counting wrapped lines
+func terminalRows(text string, width int) int {
+	rows := 0
+	for _, line := range strings.Split(strings.TrimSuffix(text, "\n"), "\n") {
+		rows++
+		if w := visibleWidth(line); width > 0 && w > width {
+			rows += (w - 1) / width
+		}
+	}
+	return rows
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNextWatchInterval(t *testing.T) {
+	initial := 2 * time.Second
+	if next := nextWatchInterval(4*time.Second, initial, false); next != 6*time.Second {
+		t.Fatal("Expected backing off while nothing changes, got:", next)
+	}
+	if next := nextWatchInterval(25*time.Second, initial, false); next != WATCH_MAX_INTERVAL {
+		t.Fatal("Expected backing off up to the maximum, got:", next)
+	}
+	if next := nextWatchInterval(25*time.Second, initial, true); next != initial {
+		t.Fatal("Expected initial interval after changes, got:", next)
+	}
+}
+
+func TestPipelineExitCode(t *testing.T) {
+	for status, expected := range map[string]int{
+		"success":  WATCH_EXIT_SUCCESS,
+		"failed":   WATCH_EXIT_FAILED,
+		"canceled": WATCH_EXIT_CANCELED,
+		"skipped":  WATCH_EXIT_CANCELED,
+		"manual":   WATCH_EXIT_MANUAL,
+	} {
+		if !pipelineFinished(status) || pipelineExitCode(status) != expected {
+			t.Fatalf("Expected %s to finish with %d, got: %d\n", status, expected, pipelineExitCode(status))
+		}
+	}
+	if pipelineFinished("running") {
+		t.Fatal("Expected running pipeline not to be finished")
+	}
+}
+
+func TestTerminalRows(t *testing.T) {
+	if rows := terminalRows("\x1b[32mbuild\x1b[0m\n"+"0123456789abcdefghij\n", 8); rows != 4 {
+		t.Fatal("Expected wrapped lines to count, got:", rows)
+	}
+}